@@ -4,3 +4,8 @@ import "embed"
 
 //go:embed filters/*.yaml
 var EmbeddedFilters embed.FS
+
+// EmbeddedTokenizer holds the BPE vocabulary used for token counting.
+//
+//go:embed tokenizer/*.tiktoken
+var EmbeddedTokenizer embed.FS
@@ -5,6 +5,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"path/filepath"
 
@@ -19,7 +20,9 @@ import (
 	"github.com/edouard-claude/snip/internal/initcmd"
 	"github.com/edouard-claude/snip/internal/inspect"
 	"github.com/edouard-claude/snip/internal/learn"
+	"github.com/edouard-claude/snip/internal/report"
 	"github.com/edouard-claude/snip/internal/tee"
+	"github.com/edouard-claude/snip/internal/tokens"
 	"github.com/edouard-claude/snip/internal/tracking"
 	"github.com/edouard-claude/snip/internal/trust"
 	"github.com/edouard-claude/snip/internal/verify"
@@ -52,6 +55,13 @@ func Run(args []string) int {
 	if flags.PluginConfig != "" {
 		_ = os.Setenv("SNIP_PLUGIN_CONFIG", flags.PluginConfig)
 	}
+	// Likewise the agent session the hook read from its payload.
+	if flags.Agent != "" {
+		_ = os.Setenv("SNIP_AGENT", flags.Agent)
+	}
+	if flags.Session != "" {
+		_ = os.Setenv("SNIP_SESSION_ID", flags.Session)
+	}
 
 	command := remaining[0]
 	cmdArgs := remaining[1:]
@@ -110,6 +120,12 @@ func Run(args []string) int {
 			return 1
 		}
 		defer func() { _ = tracker.Close() }()
+		if htmlPath, ok, err := htmlReportPath(cmdArgs); err != nil {
+			display.PrintError(err.Error())
+			return 1
+		} else if ok {
+			return writeHTMLReport(tracker, cfg.Economics, htmlPath)
+		}
 		if err := display.RunGain(tracker, cmdArgs); err != nil {
 			display.PrintError(err.Error())
 			return 1
@@ -138,6 +154,9 @@ func Run(args []string) int {
 		}
 		return 0
 
+	case "db":
+		return runDB(cmdArgs)
+
 	case "config":
 		cfg, err := config.Load()
 		if err != nil {
@@ -146,6 +165,10 @@ func Run(args []string) int {
 		}
 		fmt.Printf("tracking.db_path: %s\n", cfg.Tracking.DBPath)
 		fmt.Printf("tracking.track_unfiltered: %v\n", cfg.Tracking.TrackUnfiltered)
+		fmt.Printf("tracking.retention_days: %d\n", cfg.Tracking.RetentionDays)
+		fmt.Printf("tracking.unfiltered_retention_days: %d\n", cfg.Tracking.UnfilteredRetentionDays)
+		fmt.Printf("tracking.rollup_retention_days: %d\n", cfg.Tracking.RollupRetentionDays)
+		fmt.Printf("tracking.prometheus_textfile: %s\n", cfg.Tracking.PrometheusTextfile)
 		fmt.Printf("filters.dir: %s\n", strings.Join(cfg.Filters.Dirs(), ", "))
 		fmt.Printf("tee.mode: %s\n", cfg.Tee.Mode)
 		fmt.Printf("tee.max_files: %d\n", cfg.Tee.MaxFiles)
@@ -236,6 +259,40 @@ func Run(args []string) int {
 	return runPipeline(command, cmdArgs, flags)
 }
 
+// htmlReportPath finds "gain --html <path>". The report is rendered here
+// rather than by display.RunGain because it prices the savings with the
+// economics tiers, and economics depends on display.
+func htmlReportPath(args []string) (string, bool, error) {
+	for i, arg := range args {
+		if arg != "--html" {
+			continue
+		}
+		if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+			return "", false, fmt.Errorf("--html requires an output file")
+		}
+		return args[i+1], true, nil
+	}
+	return "", false, nil
+}
+
+func writeHTMLReport(tracker *tracking.Tracker, eco config.EconomicsConfig, path string) int {
+	f, err := os.Create(path)
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	err = report.WriteHTML(f, tracker, economics.ActiveTiers(eco), time.Now())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	fmt.Printf("wrote %s\n", path)
+	return 0
+}
+
 func parseSeparatorArgs(args []string, cmdName string) (string, []string, string) {
 	sepIdx := -1
 	for i, a := range args {
@@ -394,11 +451,27 @@ func runPipeline(command string, args []string, flags Flags) int {
 
 	registry := filter.NewRegistry(filters)
 
+	// Token estimator for savings, budgets and summaries. A bad vocabulary
+	// path falls back to the heuristic rather than failing the command.
+	if est, err := tokens.New(cfg.Tokens.Estimator, cfg.Tokens.Vocab); err != nil {
+		if flags.Verbose > 0 {
+			fmt.Fprintf(os.Stderr, "snip: %v, using %s\n", err, tokens.HeuristicName)
+		}
+	} else {
+		tokens.SetDefault(est)
+	}
+
 	// Lazy tracker: DB opens on first use (concurrently with command execution)
 	var tracker *tracking.Tracker
 	if tracking.DriverAvailable {
 		dbPath := tracking.DBPath(cfg.Tracking.DBPath)
 		tracker = tracking.NewLazyTracker(dbPath)
+		tracker.SetRetention(tracking.Retention{
+			Commands:   cfg.Tracking.RetentionDays,
+			Unfiltered: cfg.Tracking.UnfilteredRetentionDays,
+			Rollups:    cfg.Tracking.RollupRetentionDays,
+		})
+		tracker.SetPrometheusTextfile(cfg.Tracking.PrometheusTextfile)
 		defer func() { _ = tracker.Close() }()
 	}
 
@@ -499,6 +572,7 @@ Commands:
   learn           Detect CLI error-correction patterns in sessions
   verify          Run inline filter tests (--require-all to enforce coverage)
   config          Show current configuration
+  db              Tracking database: info, migrate, export, import
   trust           Trust project-local filter file(s) by SHA-256 hash
   untrust         Remove filter file(s) from the trust store
   proxy           Passthrough without filtering (optional -- separator)
@@ -533,6 +607,15 @@ Examples:
   snip gain --history 20
   snip gain --no-truncate
   snip gain --quota
+  snip gain --by filter
+  snip gain --by agent --project . --since 2026-09-01
+  snip gain --machine
+  snip gain --compare
+  snip gain --html report.html
+  snip gain --prometheus
+  snip db info
+  snip db export laptop.ndjson
+  snip db import laptop.ndjson
   snip cc-economics
   snip cc-economics --tier sonnet
   snip init
@@ -942,3 +942,41 @@ func TestRunCommandHelpAfterSeparator(t *testing.T) {
 		t.Errorf("Run(run -- git --help) = %d, want 0", code)
 	}
 }
+
+func TestDBMigrateThenInfo(t *testing.T) {
+	t.Setenv("SNIP_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	dbPath := filepath.Join(t.TempDir(), "tracking.db")
+	t.Setenv("SNIP_DB_PATH", dbPath)
+
+	var buf bytes.Buffer
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	migrateCode := Run([]string{"snip", "db", "migrate"})
+	infoCode := Run([]string{"snip", "db", "info"})
+	_ = w.Close()
+	os.Stdout = old
+	if _, err := buf.ReadFrom(r); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+
+	if migrateCode != 0 || infoCode != 0 {
+		t.Fatalf("exit codes = %d, %d; output %q", migrateCode, infoCode, buf.String())
+	}
+	out := buf.String()
+	if !strings.Contains(out, "migrated from schema version 0") || !strings.Contains(out, "add commands.redacted") || !strings.Contains(out, "machine: ") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestHTMLReportPath(t *testing.T) {
+	if path, ok, err := htmlReportPath([]string{"--daily", "--html", "out.html"}); !ok || err != nil || path != "out.html" {
+		t.Errorf("got %q, %v, %v", path, ok, err)
+	}
+	if _, ok, err := htmlReportPath([]string{"--daily"}); ok || err != nil {
+		t.Errorf("no --html: got %v, %v", ok, err)
+	}
+	if _, _, err := htmlReportPath([]string{"--html", "--json"}); err == nil {
+		t.Error("--html without a file: want error")
+	}
+}
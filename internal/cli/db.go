@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/edouard-claude/snip/internal/config"
+	"github.com/edouard-claude/snip/internal/display"
+	"github.com/edouard-claude/snip/internal/tracking"
+)
+
+const dbUsage = `Usage: snip db <subcommand>
+
+Subcommands:
+  info      Show the tracking database path, size, schema version and migrations
+  migrate   Apply pending schema migrations
+  export [FILE]
+            Write the tracked commands as NDJSON to FILE (default stdout)
+  import FILE...
+            Merge files written by 'snip db export' on other machines ("-" reads
+            stdin); rows already imported are skipped. Report with 'snip gain --machine'`
+
+// runDB handles "snip db", the tracking database maintenance commands.
+func runDB(args []string) int {
+	if !tracking.DriverAvailable {
+		display.PrintError("db requires full build (this binary was built with -tags lite)")
+		return 1
+	}
+	if len(args) == 0 || isInfoFlag(args[0]) {
+		fmt.Println(dbUsage)
+		if len(args) == 0 {
+			return 1
+		}
+		return 0
+	}
+	cfg, cfgErr := config.Load()
+	if cfgErr != nil {
+		cfg = config.DefaultConfig()
+	}
+	dbPath := tracking.DBPath(cfg.Tracking.DBPath)
+
+	switch args[0] {
+	case "info":
+		return runDBInfo(dbPath)
+	case "migrate":
+		return runDBMigrate(dbPath)
+	case "export":
+		return runDBExport(dbPath, args[1:])
+	case "import":
+		return runDBImport(dbPath, args[1:])
+	default:
+		display.PrintError(fmt.Sprintf("unknown db subcommand %q\n%s", args[0], dbUsage))
+		return 1
+	}
+}
+
+func runDBInfo(dbPath string) int {
+	info, err := tracking.Inspect(dbPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("path: %s (not created yet)\n", dbPath)
+		fmt.Printf("schema: this snip writes version %d\n", tracking.LatestVersion())
+		return 0
+	}
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+
+	fmt.Printf("path: %s (%s)\n", info.Path, formatSize(info.SizeBytes))
+	fmt.Printf("schema: version %d, this snip writes version %d\n", info.Version, info.Latest)
+	switch {
+	case info.Version > info.Latest:
+		fmt.Println("  written by a newer snip: tracking is read-only until you upgrade")
+	case len(info.Pending) > 0:
+		fmt.Println("  migrations pending: they apply on the next tracked command, or run 'snip db migrate'")
+	}
+	if info.Commands > 0 {
+		fmt.Printf("commands: %d (%s to %s)\n", info.Commands, info.Oldest, info.Newest)
+	} else {
+		fmt.Println("commands: 0")
+	}
+	fmt.Printf("unfiltered: %d\n", info.Unfiltered)
+	if info.MachineID != "" {
+		fmt.Printf("machine: %s (%d commands imported from other machines)\n", info.MachineID, info.Imported)
+	}
+
+	if len(info.Applied)+len(info.Pending) == 0 {
+		return 0
+	}
+	fmt.Println()
+	rows := make([][]string, 0, len(info.Applied)+len(info.Pending))
+	for _, m := range info.Applied {
+		rows = append(rows, []string{fmt.Sprint(m.Version), m.AppliedAt, m.Description})
+	}
+	for _, m := range info.Pending {
+		rows = append(rows, []string{fmt.Sprint(m.Version), "pending", m.Description})
+	}
+	fmt.Print(display.FormatTable([]string{"Version", "Applied", "Migration"}, rows))
+	return 0
+}
+
+func runDBMigrate(dbPath string) int {
+	from, to, err := tracking.Migrate(dbPath)
+	if errors.Is(err, tracking.ErrNewerSchema) {
+		display.PrintError(fmt.Sprintf("%s: schema version %d was written by a newer snip (this one writes %d); upgrade snip to migrate it",
+			dbPath, from, tracking.LatestVersion()))
+		return 1
+	}
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	if from == to {
+		fmt.Printf("%s is up to date (schema version %d)\n", dbPath, to)
+		return 0
+	}
+	fmt.Printf("%s migrated from schema version %d to %d\n", dbPath, from, to)
+	return 0
+}
+
+func runDBExport(dbPath string, args []string) int {
+	if len(args) > 1 {
+		display.PrintError("usage: snip db export [FILE]")
+		return 1
+	}
+	tracker, err := tracking.NewTracker(dbPath)
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	defer func() { _ = tracker.Close() }()
+
+	if len(args) == 0 || args[0] == "-" {
+		if _, err := tracker.Export(os.Stdout); err != nil {
+			display.PrintError(err.Error())
+			return 1
+		}
+		return 0
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	n, err := tracker.Export(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	fmt.Printf("exported %d commands to %s\n", n, args[0])
+	return 0
+}
+
+func runDBImport(dbPath string, args []string) int {
+	if len(args) == 0 {
+		display.PrintError("usage: snip db import FILE... (\"-\" reads stdin)")
+		return 1
+	}
+	tracker, err := tracking.NewTracker(dbPath)
+	if err != nil {
+		display.PrintError(err.Error())
+		return 1
+	}
+	defer func() { _ = tracker.Close() }()
+
+	for _, name := range args {
+		var r io.Reader = os.Stdin
+		if name != "-" {
+			f, err := os.Open(name)
+			if err != nil {
+				display.PrintError(err.Error())
+				return 1
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+		stats, err := tracker.Import(r)
+		if err != nil {
+			display.PrintError(fmt.Sprintf("%s: %v", name, err))
+			return 1
+		}
+		fmt.Printf("%s: %d rows, %d imported, %d already imported, %d from this machine\n",
+			name, stats.Rows, stats.Added, stats.Duplicates, stats.Own)
+	}
+	return 0
+}
+
+// formatSize renders a byte count in KB or MB.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -13,6 +13,11 @@ type Flags struct {
 	// config path embedded in rewritten commands by the hook (issue #169).
 	// Applied by exporting SNIP_PLUGIN_CONFIG before config loading.
 	PluginConfig string
+	// Agent and Session are the --agent and --session values the hook
+	// embeds from its payload, applied as SNIP_AGENT and SNIP_SESSION_ID
+	// so tracking records them.
+	Agent   string
+	Session string
 }
 
 // ParseFlags extracts global flags from args and returns remaining args.
@@ -40,6 +45,13 @@ func ParseFlags(args []string) (Flags, []string) {
 			skipNext = true
 		case arg == "--plugin-config":
 			// Missing value: ignore the dangling flag.
+		case (arg == "--agent" || arg == "--session") && i+1 < len(args):
+			if arg == "--agent" {
+				flags.Agent = args[i+1]
+			} else {
+				flags.Session = args[i+1]
+			}
+			skipNext = true
 		case arg == "-vv":
 			flags.Verbose = 2
 		case arg == "-v":
@@ -78,7 +90,7 @@ func isStackedVerboseFlag(arg string) bool {
 
 func isBuiltInCommand(arg string) bool {
 	switch arg {
-	case "run", "check", "init", "gain", "cc-economics", "config", "proxy", "hook", "hook-audit", "discover", "learn", "verify", "trust", "untrust", "inspect":
+	case "run", "check", "init", "gain", "cc-economics", "config", "db", "proxy", "hook", "hook-audit", "discover", "learn", "verify", "trust", "untrust", "inspect":
 		return true
 	default:
 		return false
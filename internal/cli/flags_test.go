@@ -185,3 +185,13 @@ func TestParseFlagsPluginConfigMissingValue(t *testing.T) {
 		t.Errorf("remaining: got %v, want empty", remaining)
 	}
 }
+
+func TestParseFlagsAgentSession(t *testing.T) {
+	flags, remaining := ParseFlags([]string{"--agent", "claude-code", "--session", "abc-123", "run", "--", "git", "status"})
+	if flags.Agent != "claude-code" || flags.Session != "abc-123" {
+		t.Errorf("Agent, Session: got %q, %q, want claude-code, abc-123", flags.Agent, flags.Session)
+	}
+	if len(remaining) != 4 || remaining[0] != "run" {
+		t.Errorf("remaining: got %v, want [run -- git status]", remaining)
+	}
+}
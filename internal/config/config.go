@@ -21,6 +21,17 @@ type Config struct {
 	Filters   FiltersConfig   `toml:"filters"`
 	Tee       TeeConfig       `toml:"tee"`
 	Economics EconomicsConfig `toml:"economics"`
+	Tokens    TokensConfig    `toml:"tokens"`
+}
+
+// TokensConfig selects how tokens are counted for savings, budgets and
+// summaries. Estimator is "bpe" (default), which tokenizes with the embedded
+// cl100k_base vocabulary or with Vocab, the tiktoken-format rank file of
+// another model's tokenizer such as o200k_base.tiktoken, or "chars4", one
+// token per four bytes.
+type TokensConfig struct {
+	Estimator string `toml:"estimator"`
+	Vocab     string `toml:"vocab"`
 }
 
 // EconomicsConfig holds the pricing tiers used by cc-economics. Keys are
@@ -37,6 +48,17 @@ type TrackingConfig struct {
 	// gaps. Off by default to keep the passthrough path free of extra work.
 	// See issue #96.
 	TrackUnfiltered bool `toml:"track_unfiltered"`
+	// RetentionDays is how long each tracked command is kept; older ones
+	// survive as daily per-filter rollups, which are kept
+	// RollupRetentionDays. UnfilteredRetentionDays applies to the
+	// track_unfiltered records. 0 keeps data forever.
+	RetentionDays           int `toml:"retention_days"`
+	UnfilteredRetentionDays int `toml:"unfiltered_retention_days"`
+	RollupRetentionDays     int `toml:"rollup_retention_days"`
+	// PrometheusTextfile, when set, is rewritten with the savings counters
+	// after each tracked command, for node_exporter's textfile collector
+	// (e.g. /var/lib/node_exporter/textfile/snip.prom).
+	PrometheusTextfile string `toml:"prometheus_textfile"`
 }
 
 type DisplayConfig struct {
@@ -64,6 +86,15 @@ type FilterGlobalConfig struct {
 	MaxLineLength  int    `toml:"max_line_length"`  // 0 = unlimited
 	MaxOutputBytes int    `toml:"max_output_bytes"` // 0 = unlimited
 	StreamMode     string `toml:"stream_mode"`      // "filter" | "full"
+	// MaxOutputTokens trims every filter's output to this many estimated
+	// tokens with the budget action, dropping the lowest-priority lines
+	// first. It runs before max_output_bytes, so the byte cap only cuts
+	// blindly when the budget alone was not enough. 0 = unlimited.
+	MaxOutputTokens int `toml:"max_output_tokens"`
+	// Redact replaces secrets (cloud keys, tokens, JWTs, private keys) in
+	// every filtered command's output before the pipeline runs, so tee files
+	// are redacted too.
+	Redact bool `toml:"redact"`
 }
 
 // FilterOverride overrides specific pipeline action parameters for a named filter.
@@ -127,7 +158,9 @@ func DefaultConfig() *Config {
 	}
 	return &Config{
 		Tracking: TrackingConfig{
-			DBPath: filepath.Join(home, ".local", "share", "snip", "tracking.db"),
+			DBPath:                  filepath.Join(home, ".local", "share", "snip", "tracking.db"),
+			RetentionDays:           90,
+			UnfilteredRetentionDays: 14,
 		},
 		Display: DisplayConfig{
 			Color:   true,
@@ -143,6 +176,9 @@ func DefaultConfig() *Config {
 			MaxFiles:    20,
 			MaxFileSize: 1 << 20, // 1MB
 		},
+		Tokens: TokensConfig{
+			Estimator: "bpe",
+		},
 	}
 }
 
@@ -193,6 +229,7 @@ func tryUnmarshalArrayDir(data []byte, cfg *Config) bool {
 		Filters   filtersArray    `toml:"filters"`
 		Tee       TeeConfig       `toml:"tee"`
 		Economics EconomicsConfig `toml:"economics"`
+		Tokens    TokensConfig    `toml:"tokens"`
 	}
 
 	def := DefaultConfig()
@@ -202,6 +239,7 @@ func tryUnmarshalArrayDir(data []byte, cfg *Config) bool {
 		Display:  def.Display,
 		Filters:  filtersArray{Dir: def.Filters.Dirs()},
 		Tee:      def.Tee,
+		Tokens:   def.Tokens,
 	}
 
 	if err := toml.Unmarshal(data, &alt); err != nil {
@@ -219,6 +257,7 @@ func tryUnmarshalArrayDir(data []byte, cfg *Config) bool {
 	cfg.Filters.Bypass = alt.Filters.Bypass
 	cfg.Tee = alt.Tee
 	cfg.Economics = alt.Economics
+	cfg.Tokens = alt.Tokens
 	return true
 }
 
@@ -235,6 +274,12 @@ func (c *Config) expandPaths() {
 		return
 	}
 	c.Tracking.DBPath = expandPath(expandEnvVars(c.Tracking.DBPath), home)
+	if c.Tracking.PrometheusTextfile != "" {
+		c.Tracking.PrometheusTextfile = expandPath(expandEnvVars(c.Tracking.PrometheusTextfile), home)
+	}
+	if c.Tokens.Vocab != "" {
+		c.Tokens.Vocab = expandPath(expandEnvVars(c.Tokens.Vocab), home)
+	}
 
 	dirs := c.Filters.Dirs()
 	expanded := make([]string, len(dirs))
@@ -458,7 +503,7 @@ func LoadMerged() (*Config, error) {
 			merged.Filters.Enable[k] = v
 		}
 		// Global limits: project wins entirely
-		if project.Filters.Global.MaxLines > 0 || project.Filters.Global.MaxLineLength > 0 || project.Filters.Global.MaxOutputBytes > 0 || project.Filters.Global.StreamMode != "" {
+		if project.Filters.Global.MaxLines > 0 || project.Filters.Global.MaxLineLength > 0 || project.Filters.Global.MaxOutputBytes > 0 || project.Filters.Global.MaxOutputTokens > 0 || project.Filters.Global.Redact || project.Filters.Global.StreamMode != "" {
 			merged.Filters.Global = project.Filters.Global
 		}
 		// Per-filter overrides: project wins
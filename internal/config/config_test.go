@@ -1729,3 +1729,34 @@ opus = 4.20
 		t.Errorf("Tiers[opus] after array-dir fallback: got %v, want 4.20", cfg.Economics.Tiers["opus"])
 	}
 }
+
+func TestLoadConfigTokens(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	tests := []struct {
+		name    string
+		content string
+		want    TokensConfig
+	}{
+		{"default", "", TokensConfig{Estimator: "bpe"}},
+		{"heuristic", "[tokens]\nestimator = \"chars4\"\n", TokensConfig{Estimator: "chars4"}},
+		{"vocab with array dir", "[filters]\ndir = [\"/tmp/a\"]\n\n[tokens]\nestimator = \"bpe\"\nvocab = \"~/cl100k_base.tiktoken\"\n",
+			TokensConfig{Estimator: "bpe", Vocab: filepath.Join(home, "cl100k_base.tiktoken")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("SNIP_CONFIG", path)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Tokens != tt.want {
+				t.Errorf("Tokens: got %+v, want %+v", cfg.Tokens, tt.want)
+			}
+		})
+	}
+}
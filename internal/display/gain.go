@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/edouard-claude/snip/internal/tracking"
 	"github.com/edouard-claude/snip/internal/utils"
@@ -27,10 +31,17 @@ func RunGain(tracker *tracking.Tracker, args []string) error {
 		showTop        bool
 		showQuota      bool
 		showUnfiltered bool
+		showProm       bool
 		noTruncate     bool
 		historyN       int
 		topN           int
 		days           = 7
+		by             string
+		scope          tracking.Scope
+		project        string
+		compare        bool
+		ranges         string
+		threshold      = 10.0
 	)
 
 	for i := 0; i < len(args); i++ {
@@ -75,7 +86,72 @@ func RunGain(tracker *tracking.Tracker, args []string) error {
 			}
 		case "--no-truncate":
 			noTruncate = true
+		case "--prometheus":
+			showProm = true
+		case "--machine":
+			by = "machine"
+		case "--compare":
+			compare = true
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				ranges = args[i+1]
+				i++
+			}
+		case "--threshold":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--threshold requires a value")
+			}
+			if _, err := fmt.Sscanf(args[i+1], "%g", &threshold); err != nil {
+				return fmt.Errorf("--threshold %q: want a number of percentage points", args[i+1])
+			}
+			i++
+		case "--by", "--project", "--agent", "--since", "--until":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--by":
+				by = args[i+1]
+			case "--project":
+				project = args[i+1]
+			case "--agent":
+				scope.Agent = args[i+1]
+			case "--since":
+				scope.Since = args[i+1]
+			case "--until":
+				scope.Until = args[i+1]
+			}
+			i++
+		}
+	}
+
+	if showProm {
+		return tracker.WritePrometheus(os.Stdout)
+	}
+
+	if project != "" {
+		root, err := resolveProject(project)
+		if err != nil {
+			return err
+		}
+		scope.ProjectRoot = root
+	}
+	if compare {
+		if scope.Since != "" || scope.Until != "" {
+			return fmt.Errorf("--compare takes its periods as FROM..TO,FROM..TO, not --since/--until")
+		}
+		base, current, err := parseCompareRanges(ranges, time.Now())
+		if err != nil {
+			return err
 		}
+		base.ProjectRoot, base.Agent = scope.ProjectRoot, scope.Agent
+		current.ProjectRoot, current.Agent = scope.ProjectRoot, scope.Agent
+		return runCompare(tracker, base, current, threshold, topN, showJSON, noTruncate)
+	}
+	if by == "" && scope != (tracking.Scope{}) {
+		return fmt.Errorf("--project, --agent, --since and --until scope a breakdown: add --by %s", strings.Join(tracking.BreakdownDimensions(), "|"))
+	}
+	if by != "" {
+		return runBreakdown(tracker, by, scope, topN, showJSON, showCSV, noTruncate)
 	}
 
 	summary, err := tracker.GetSummary()
@@ -179,6 +255,12 @@ func printSummary(s *tracking.Summary) {
 	printKPI("Avg savings", ColorSavings(s.AvgSavings), true)
 	printKPI("Efficiency", ColorTier(tier), true)
 	printKPI("Total time", fmt.Sprintf("%.1fs", float64(s.TotalTimeMs)/1000), false)
+	if s.TotalRedacted > 0 {
+		printKPI("Secrets redacted", fmt.Sprintf("%d", s.TotalRedacted), false)
+	}
+	if len(s.Estimators) > 1 {
+		printKPI("Token counters", formatEstimators(s.Estimators), false)
+	}
 
 	// Efficiency bar
 	pct := s.AvgSavings
@@ -505,6 +587,318 @@ func showHistory(tracker *tracking.Tracker, n int, noTruncate bool) error {
 	return nil
 }
 
+// resolveProject turns a --project argument into the project root stored
+// with each command: the repository enclosing the path, or the path itself
+// when it is not inside one.
+func resolveProject(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("--project %s: %w", path, err)
+	}
+	if root := tracking.ProjectRoot(abs); root != "" {
+		return root, nil
+	}
+	return abs, nil
+}
+
+// runBreakdown shows stats grouped by filter, project, agent or exit status,
+// as a table or, with --json or --csv, as an export.
+func runBreakdown(tracker *tracking.Tracker, by string, scope tracking.Scope, limit int, asJSON, asCSV, noTruncate bool) error {
+	groups, err := tracker.GetBreakdown(by, scope, limit)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		data := map[string]any{
+			"by":     by,
+			"scope":  scope,
+			"groups": groups,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	if asCSV {
+		return exportBreakdownCSV(by, groups)
+	}
+
+	// The summary covers the same commands as the table below it.
+	summary := &tracking.Summary{}
+	input := 0
+	for _, g := range groups {
+		summary.TotalCommands += g.Count
+		summary.TotalSaved += g.SavedTokens
+		summary.TotalTimeMs += g.ExecTimeMs
+		input += g.InputTokens
+	}
+	if input > 0 {
+		summary.AvgSavings = float64(summary.TotalSaved) * 100 / float64(input)
+	}
+	printSummary(summary)
+
+	tty := IsTerminal()
+	title := "  Tokens saved by " + by
+	if desc := describeScope(scope); desc != "" {
+		title += " (" + desc + ")"
+	}
+	if tty {
+		fmt.Println(DimStyle.Render(title))
+	} else {
+		fmt.Println(title)
+	}
+	fmt.Println()
+
+	if len(groups) == 0 {
+		fmt.Println("  (no commands tracked in this scope)")
+		fmt.Println()
+		return nil
+	}
+
+	maxSaved := 0
+	for _, g := range groups {
+		if g.SavedTokens > maxSaved {
+			maxSaved = g.SavedTokens
+		}
+	}
+
+	headers := []string{strings.ToUpper(by[:1]) + by[1:], "Runs", "Saved", "Savings", "Fallbacks", "Impact"}
+	var rows [][]string
+	// Fixed columns: Runs(4) + Saved(5) + Savings(7) + Fallbacks(9) + Impact(12) + 5×sep(10) = 47
+	maxGroup := cmdColWidth(TerminalWidth(), 47)
+	for _, g := range groups {
+		// Keep the end of long project paths, where they differ.
+		name := g.Group
+		if !noTruncate && len(name) > maxGroup {
+			name = "..." + name[len(name)-maxGroup+3:]
+		}
+		rows = append(rows, []string{
+			name,
+			fmt.Sprintf("%d", g.Count),
+			utils.FormatTokens(g.SavedTokens),
+			ColorSavings(g.AvgSavings),
+			fmt.Sprintf("%d", g.RawFallbacks),
+			ColorBar(g.SavedTokens, maxSaved, 12),
+		})
+	}
+
+	fmt.Print(FormatTable(headers, rows))
+	fmt.Println()
+	return nil
+}
+
+// describeScope renders the non-empty scope fields for a report title.
+func describeScope(s tracking.Scope) string {
+	var parts []string
+	if s.ProjectRoot != "" {
+		parts = append(parts, "project "+s.ProjectRoot)
+	}
+	if s.Agent != "" {
+		parts = append(parts, "agent "+s.Agent)
+	}
+	if s.Since != "" {
+		parts = append(parts, "since "+s.Since)
+	}
+	if s.Until != "" {
+		parts = append(parts, "until "+s.Until)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func exportBreakdownCSV(by string, groups []tracking.GroupStats) error {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{by, "commands", "input_tokens", "output_tokens", "saved_tokens", "avg_savings", "exec_time_ms", "raw_fallbacks"})
+	for _, g := range groups {
+		_ = w.Write([]string{
+			g.Group,
+			fmt.Sprintf("%d", g.Count),
+			fmt.Sprintf("%d", g.InputTokens),
+			fmt.Sprintf("%d", g.OutputTokens),
+			fmt.Sprintf("%d", g.SavedTokens),
+			fmt.Sprintf("%.1f", g.AvgSavings),
+			fmt.Sprintf("%d", g.ExecTimeMs),
+			fmt.Sprintf("%d", g.RawFallbacks),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// parseCompareRanges reads the --compare periods, "FROM..TO,FROM..TO" (base
+// then current, days inclusive). Empty compares the last 7 days, today
+// included, with the 7 before.
+func parseCompareRanges(spec string, now time.Time) (base, current tracking.Scope, err error) {
+	if spec == "" {
+		day := func(offset int) string { return now.AddDate(0, 0, offset).Format("2006-01-02") }
+		return tracking.Scope{Since: day(-13), Until: day(-7)}, tracking.Scope{Since: day(-6), Until: day(0)}, nil
+	}
+	periods := strings.Split(spec, ",")
+	if len(periods) != 2 {
+		return base, current, fmt.Errorf("--compare %q: want FROM..TO,FROM..TO", spec)
+	}
+	var scopes [2]tracking.Scope
+	for i, p := range periods {
+		from, to, ok := strings.Cut(p, "..")
+		if !ok {
+			return base, current, fmt.Errorf("--compare %q: period %q is not FROM..TO", spec, p)
+		}
+		for _, d := range []string{from, to} {
+			if _, err := time.Parse("2006-01-02", d); err != nil {
+				return base, current, fmt.Errorf("--compare %q: %q is not YYYY-MM-DD", spec, d)
+			}
+		}
+		scopes[i] = tracking.Scope{Since: from, Until: to}
+	}
+	return scopes[0], scopes[1], nil
+}
+
+// runCompare shows how savings moved between two periods, per filter and
+// per command, and flags filters whose savings ratio dropped by more than
+// threshold points.
+func runCompare(tracker *tracking.Tracker, base, current tracking.Scope, threshold float64, limit int, asJSON, noTruncate bool) error {
+	filters, err := tracker.Compare("filter", base, current, threshold)
+	if err != nil {
+		return err
+	}
+	commands, err := tracker.Compare("command", base, current, threshold)
+	if err != nil {
+		return err
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	if len(commands) > limit {
+		commands = commands[:limit]
+	}
+	var regressions []string
+	for _, d := range filters {
+		if d.Regression {
+			regressions = append(regressions, d.Group)
+		}
+	}
+
+	if asJSON {
+		period := func(s tracking.Scope) map[string]string {
+			return map[string]string{"since": s.Since, "until": s.Until}
+		}
+		data := map[string]any{
+			"base":        period(base),
+			"current":     period(current),
+			"threshold":   threshold,
+			"filters":     filters,
+			"commands":    commands,
+			"regressions": regressions,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+
+	tty := IsTerminal()
+	printDim := func(s string) {
+		if tty {
+			fmt.Println(DimStyle.Render(s))
+		} else {
+			fmt.Println(s)
+		}
+	}
+
+	fmt.Println()
+	printDim(fmt.Sprintf("  Savings %s..%s vs %s..%s", current.Since, current.Until, base.Since, base.Until))
+	fmt.Println()
+	if len(filters) == 0 {
+		fmt.Println("  (no commands tracked in either period)")
+		fmt.Println()
+		return nil
+	}
+
+	printDim("  By filter")
+	fmt.Println()
+	fmt.Print(FormatTable(compareHeaders("Filter"), compareRows(filters, noTruncate)))
+	fmt.Println()
+	printDim("  By command")
+	fmt.Println()
+	fmt.Print(FormatTable(compareHeaders("Command"), compareRows(commands, noTruncate)))
+	fmt.Println()
+
+	if len(regressions) > 0 {
+		msg := fmt.Sprintf("  %d filter(s) lost more than %g points of savings: %s", len(regressions), threshold, strings.Join(regressions, ", "))
+		if tty {
+			fmt.Println(ErrorStyle.Render(msg))
+		} else {
+			fmt.Println(msg)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func compareHeaders(group string) []string {
+	return []string{group, "Runs", "Savings", "Δ Savings", "Δ Saved", ""}
+}
+
+func compareRows(deltas []tracking.Delta, noTruncate bool) [][]string {
+	// Fixed columns: Runs(9) + Savings(15) + Δ Savings(10) + Δ Saved(7) + flag(10) + 5×sep(10) = 61
+	maxGroup := cmdColWidth(TerminalWidth(), 61)
+	rows := make([][]string, 0, len(deltas))
+	for _, d := range deltas {
+		name := d.Group
+		if !noTruncate && len(name) > maxGroup {
+			name = name[:maxGroup-3] + "..."
+		}
+		savings, delta := "-", "-"
+		switch {
+		case d.Base.Count > 0 && d.Current.Count > 0:
+			savings = fmt.Sprintf("%.0f%% → %.0f%%", d.Base.AvgSavings, d.Current.AvgSavings)
+			delta = fmt.Sprintf("%+.1f pts", d.SavingsDelta)
+		case d.Current.Count > 0:
+			savings = fmt.Sprintf("new, %.0f%%", d.Current.AvgSavings)
+		default:
+			savings = fmt.Sprintf("%.0f%%, gone", d.Base.AvgSavings)
+		}
+		flag := ""
+		if d.Regression {
+			flag = "regression"
+		}
+		rows = append(rows, []string{
+			name,
+			fmt.Sprintf("%d → %d", d.Base.Count, d.Current.Count),
+			savings,
+			delta,
+			formatSignedTokens(d.SavedDelta),
+			flag,
+		})
+	}
+	return rows
+}
+
+// formatSignedTokens is utils.FormatTokens with an explicit sign.
+func formatSignedTokens(n int) string {
+	if n < 0 {
+		return "-" + utils.FormatTokens(-n)
+	}
+	return "+" + utils.FormatTokens(n)
+}
+
+// formatEstimators lists estimators by how many commands each measured, so a
+// history mixing heuristic and tokenizer counts is visible in the summary.
+func formatEstimators(m map[string]int) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if m[names[i]] != m[names[j]] {
+			return m[names[i]] > m[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%d)", name, m[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
 func exportJSON(summary *tracking.Summary, tracker *tracking.Tracker, days int) error {
 	daily, _ := tracker.GetDaily(days)
 	byCmd, _ := tracker.GetByCommand(10)
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/edouard-claude/snip/internal/tracking"
 )
@@ -321,3 +322,88 @@ func TestFormatQuotaCost(t *testing.T) {
 		}
 	}
 }
+
+func TestRunGainBy(t *testing.T) {
+	tracker := newTestTracker(t)
+	_ = tracker.TrackRecord(tracking.Record{OriginalCmd: "go test ./a", SnipCmd: "go test ./a", InputTokens: 1000, OutputTokens: 100, Filter: "go-test", Agent: "cursor"})
+	_ = tracker.TrackRecord(tracking.Record{OriginalCmd: "go test ./b", SnipCmd: "go test ./b", InputTokens: 500, OutputTokens: 100, Filter: "go-test", Agent: "claude-code"})
+
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	runErr := RunGain(tracker, []string{"--by", "filter", "--agent", "cursor", "--csv"})
+
+	_ = w.Close()
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	os.Stdout = old
+
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+	want := "filter,commands,input_tokens,output_tokens,saved_tokens,avg_savings,exec_time_ms,raw_fallbacks\ngo-test,1,1000,100,900,90.0,0,0\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	if err := RunGain(tracker, []string{"--agent", "cursor"}); err == nil {
+		t.Error("scope without --by: want error")
+	}
+	if err := RunGain(tracker, []string{"--by", "color"}); err == nil {
+		t.Error("unknown dimension: want error")
+	}
+}
+
+func TestParseCompareRanges(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	base, current, err := parseCompareRanges("", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Since != "2026-10-05" || base.Until != "2026-10-11" || current.Since != "2026-10-12" || current.Until != "2026-10-18" {
+		t.Errorf("default = %+v vs %+v", base, current)
+	}
+
+	base, current, err = parseCompareRanges("2026-08-01..2026-08-31,2026-09-01..2026-09-30", now)
+	if err != nil || base.Since != "2026-08-01" || current.Until != "2026-09-30" {
+		t.Errorf("explicit = %+v vs %+v, %v", base, current, err)
+	}
+
+	for _, bad := range []string{"2026-08-01..2026-08-31", "2026-08-01,2026-09-01", "2026-08-01..x,2026-09-01..2026-09-30"} {
+		if _, _, err := parseCompareRanges(bad, now); err == nil {
+			t.Errorf("%q: want error", bad)
+		}
+	}
+}
+
+func TestRunGainCompareJSON(t *testing.T) {
+	tracker := newTestTracker(t)
+	seedTracker(t, tracker)
+
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	runErr := RunGain(tracker, []string{"--compare", "--json"})
+
+	_ = w.Close()
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	os.Stdout = old
+
+	if runErr != nil {
+		t.Fatalf("unexpected error: %v", runErr)
+	}
+	for _, key := range []string{`"base"`, `"current"`, `"filters"`, `"commands"`, `"regressions"`} {
+		if !strings.Contains(buf.String(), key) {
+			t.Errorf("JSON missing %s: %s", key, buf.String())
+		}
+	}
+}
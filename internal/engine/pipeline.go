@@ -6,13 +6,14 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/edouard-claude/snip/internal/config"
 	"github.com/edouard-claude/snip/internal/filter"
 	"github.com/edouard-claude/snip/internal/hook"
 	"github.com/edouard-claude/snip/internal/tee"
+	"github.com/edouard-claude/snip/internal/tokens"
 	"github.com/edouard-claude/snip/internal/tracking"
-	"github.com/edouard-claude/snip/internal/utils"
 )
 
 // Pipeline orchestrates command execution, filtering, tracking, and tee.
@@ -129,8 +130,12 @@ func (p *Pipeline) Run(command string, args []string) int {
 	// Start timing
 	timed := tracking.Start(p.Tracker)
 
-	// Execute command
-	result, err := p.runCommand(command, finalArgs)
+	// Execute command. Report placeholders are resolved only for execution, so
+	// the summary and tracking record the stable "{report}" form.
+	execArgs, reportPath, cleanupReport := prepareReport(finalArgs)
+	defer cleanupReport()
+	started := time.Now()
+	result, err := p.runCommand(command, execArgs)
 	if result == nil {
 		// The command never ran, so fall back to passthrough. A non-nil result
 		// with a non-nil err means it did run, and re-running it here would
@@ -154,6 +159,18 @@ func (p *Pipeline) Run(command string, args []string) int {
 	// Build pipeline input from selected streams
 	pipelineInput := buildPipelineInput(f, result)
 
+	// Global redaction runs on the input rather than the output, so the raw
+	// fallbacks below and the tee file never carry the secret either.
+	redacted := 0
+	if p.Config != nil && p.Config.Filters.Global.Redact {
+		pipelineInput, redacted = filter.RedactText(pipelineInput)
+		if !f.HasStream("stderr") {
+			var n int
+			result.Stderr, n = filter.RedactText(result.Stderr)
+			redacted += n
+		}
+	}
+
 	// Apply project-level filter overrides to the matched filter pipeline.
 	// Clone the filter first to avoid mutating the registry's shared pointer.
 	if p.Config != nil {
@@ -161,19 +178,35 @@ func (p *Pipeline) Run(command string, args []string) int {
 		if override, ok := p.Config.Filters.Override[f.Name]; ok {
 			applyOverride(f, &override)
 		}
-		if p.Config.Filters.Global.MaxLines > 0 || p.Config.Filters.Global.MaxLineLength > 0 || p.Config.Filters.Global.MaxOutputBytes > 0 {
+		if p.Config.Filters.Global.MaxLines > 0 || p.Config.Filters.Global.MaxLineLength > 0 || p.Config.Filters.Global.MaxOutputBytes > 0 || p.Config.Filters.Global.MaxOutputTokens > 0 {
 			applyGlobalLimit(f, &p.Config.Filters.Global)
 		}
 	}
 
-	// Apply filter pipeline
-	filtered, filterErr := ApplyPipeline(f, pipelineInput)
+	// Apply filter pipeline, handing it the run context and any test
+	// reports the run produced
+	cwd, _ := os.Getwd()
+	meta := map[string]any{filter.RunKey: filter.RunContext{
+		ExitCode: result.ExitCode,
+		Duration: result.Duration,
+		Cwd:      cwd,
+		Argv:     append([]string{command}, finalArgs...),
+	}}
+	if reports := collectReports(f, reportPath, started); len(reports) > 0 {
+		meta[filter.ReportsKey] = reports
+	}
+	filtered, meta, filterErr := applyPipeline(f, pipelineInput, meta)
+	if n, ok := meta[filter.RedactedKey].(int); ok && filterErr == nil {
+		redacted += n
+	}
+	rawFallback := false
 	if filterErr != nil {
 		// Graceful degradation: use raw output
 		if p.Verbose > 0 {
 			fmt.Fprintf(os.Stderr, "snip: filter error: %v\n", filterErr)
 		}
 		filtered = pipelineInput
+		rawFallback = true
 	}
 
 	// Safety net: a filter that strips every line would send empty output to
@@ -186,11 +219,12 @@ func (p *Pipeline) Run(command string, args []string) int {
 			fmt.Fprintf(os.Stderr, "snip: filter %q produced empty output, using raw\n", f.Name)
 		}
 		filtered = pipelineInput
+		rawFallback = true
 	}
 
 	// Compute token counts before summary so we can use savings as the budget
-	inputTokens := utils.EstimateTokens(pipelineInput)
-	filteredTokens := utils.EstimateTokens(filtered)
+	inputTokens := tokens.Count(pipelineInput)
+	filteredTokens := tokens.Count(filtered)
 
 	// Apply summary line (additive only — never removes content)
 	if p.summaryEnabled() && filterErr == nil {
@@ -230,8 +264,22 @@ func (p *Pipeline) Run(command string, args []string) int {
 	if inputTokens > 0 {
 		originalCmd := command + " " + strings.Join(fullArgs, " ")
 		snipCmd := command + " " + strings.Join(finalArgs, " ")
-		outputTokens := utils.EstimateTokens(filtered)
-		if err := timed.Track(originalCmd, snipCmd, inputTokens, outputTokens); err != nil && !errors.Is(err, tracking.ErrUnavailable) {
+		outputTokens := tokens.Count(filtered)
+		err := timed.TrackRecord(tracking.Record{
+			OriginalCmd:   originalCmd,
+			SnipCmd:       snipCmd,
+			InputTokens:   inputTokens,
+			OutputTokens:  outputTokens,
+			Redacted:      redacted,
+			Estimator:     tokens.Default().Name(),
+			Filter:        f.Name,
+			FilterVersion: f.Version,
+			ExitCode:      result.ExitCode,
+			Cwd:           cwd,
+			RawFallback:   rawFallback,
+			FilterError:   filterErr != nil,
+		})
+		if err != nil && !errors.Is(err, tracking.ErrUnavailable) {
 			// A genuine runtime tracking error is surfaced; an unwritable DB
 			// (sandboxed/read-only filesystem) degrades silently so hooks don't
 			// emit a stderr line on every command. See issue #93.
@@ -402,6 +450,13 @@ func (p *Pipeline) isFilterEnabled(name string) bool {
 
 // ApplyPipeline executes filter actions sequentially.
 func ApplyPipeline(f *filter.Filter, input string) (string, error) {
+	out, _, err := applyPipeline(f, input, nil)
+	return out, err
+}
+
+// applyPipeline is ApplyPipeline with initial metadata for the first action.
+// It also returns the metadata the last action left behind.
+func applyPipeline(f *filter.Filter, input string, meta map[string]any) (string, map[string]any, error) {
 	lines := strings.Split(input, "\n")
 	// Remove trailing empty line from split
 	if len(lines) > 0 && lines[len(lines)-1] == "" {
@@ -416,25 +471,24 @@ func ApplyPipeline(f *filter.Filter, input string) (string, error) {
 		}
 	}
 
+	if meta == nil {
+		meta = make(map[string]any)
+	}
+	// Templates can read .run even when the caller has no command to describe.
+	if _, ok := meta[filter.RunKey]; !ok {
+		meta[filter.RunKey] = filter.RunContext{}
+	}
 	result := filter.ActionResult{
 		Lines:    lines,
-		Metadata: make(map[string]any),
+		Metadata: meta,
 	}
 
-	for i, action := range f.Pipeline {
-		fn, ok := filter.GetAction(action.ActionName)
-		if !ok {
-			return "", fmt.Errorf("unknown action %q at pipeline[%d]", action.ActionName, i)
-		}
-
-		var err error
-		result, err = fn(result, action.Params)
-		if err != nil {
-			return "", fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
-		}
+	result, err := filter.RunPipeline(f.Pipeline, result)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return strings.Join(result.Lines, "\n") + "\n", nil
+	return strings.Join(result.Lines, "\n") + "\n", result.Metadata, nil
 }
 
 // applyOverride modifies a filter's pipeline actions based on project config
@@ -512,9 +566,10 @@ func applyOverride(f *filter.Filter, o *config.FilterOverride) {
 	}
 }
 
-// applyGlobalLimit appends global limits (max_lines, max_line_length, max_output_bytes)
-// to the end of a filter's pipeline. These act as a final safety cap on all
-// filtered output.
+// applyGlobalLimit appends global limits (max_lines, max_line_length,
+// max_output_tokens, max_output_bytes) to the end of a filter's pipeline. These
+// act as a final safety cap on all filtered output. The token budget runs
+// before the byte cap so priority-aware trimming gets the first chance.
 func applyGlobalLimit(f *filter.Filter, g *config.FilterGlobalConfig) {
 	if g.MaxLines > 0 {
 		f.Pipeline = append(f.Pipeline, filter.Action{
@@ -528,6 +583,12 @@ func applyGlobalLimit(f *filter.Filter, g *config.FilterGlobalConfig) {
 			Params:     map[string]any{"max": g.MaxLineLength},
 		})
 	}
+	if g.MaxOutputTokens > 0 {
+		f.Pipeline = append(f.Pipeline, filter.Action{
+			ActionName: "budget",
+			Params:     map[string]any{"tokens": g.MaxOutputTokens},
+		})
+	}
 	if g.MaxOutputBytes > 0 {
 		f.Pipeline = append(f.Pipeline, filter.Action{
 			ActionName: "truncate_bytes",
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edouard-claude/snip/internal/filter"
+)
+
+// reportDirName is the directory beside the report path that stands in for
+// filter.ReportDirPlaceholder.
+const reportDirName = "reports"
+
+// prepareReport replaces filter.ReportPlaceholder in args with a path inside
+// a fresh temporary directory, and filter.ReportDirPlaceholder with a
+// directory beside it, so a tool told to write a report there (pytest
+// --junitxml, for instance) never collides with another run. It returns the
+// args to execute, the report path ("" when no arg used a placeholder) and
+// a cleanup func that removes the directory.
+func prepareReport(args []string) ([]string, string, func()) {
+	uses := false
+	for _, a := range args {
+		if strings.Contains(a, filter.ReportPlaceholder) || strings.Contains(a, filter.ReportDirPlaceholder) {
+			uses = true
+			break
+		}
+	}
+	if !uses {
+		return args, "", func() {}
+	}
+
+	dir, err := os.MkdirTemp("", "snip-report-")
+	if err != nil {
+		// Without a directory the placeholder cannot be honoured; running the
+		// command with a literal "{report}" is still better than not running it.
+		return args, "", func() {}
+	}
+	path := filepath.Join(dir, "report.xml")
+	out := make([]string, len(args))
+	for i, a := range args {
+		a = strings.ReplaceAll(a, filter.ReportDirPlaceholder, filepath.Join(dir, reportDirName))
+		out[i] = strings.ReplaceAll(a, filter.ReportPlaceholder, path)
+	}
+	return out, path, func() { os.RemoveAll(dir) }
+}
+
+// collectReports reads the report written to path and the files in the
+// report directory beside it, if any, and every file matching the filter's inject.reports globs that was modified at or after
+// since. Reports left behind by earlier runs are ignored, so a build that
+// fails before testing is not summarised with stale results. Unreadable files
+// are skipped.
+func collectReports(f *filter.Filter, path string, since time.Time) []string {
+	var docs []string
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			docs = append(docs, string(data))
+		}
+		reportDir := filepath.Join(filepath.Dir(path), reportDirName)
+		entries, _ := os.ReadDir(reportDir)
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if data, err := os.ReadFile(filepath.Join(reportDir, e.Name())); err == nil {
+				docs = append(docs, string(data))
+			}
+		}
+	}
+	if f.Inject == nil {
+		return docs
+	}
+	// Filesystems with coarse timestamps round mtimes down, so compare at
+	// second granularity.
+	cutoff := since.Truncate(time.Second)
+	seen := make(map[string]bool)
+	for _, pattern := range f.Inject.Reports {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+			info, err := os.Stat(m)
+			if err != nil || info.IsDir() || info.ModTime().Before(cutoff) {
+				continue
+			}
+			if data, err := os.ReadFile(m); err == nil {
+				docs = append(docs, string(data))
+			}
+		}
+	}
+	return docs
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/edouard-claude/snip/internal/tokens"
 	"github.com/edouard-claude/snip/internal/utils"
 )
 
@@ -62,7 +63,7 @@ func PrependSummary(filtered string, summary string, inputTokens, filteredTokens
 		return filtered
 	}
 
-	summaryTokens := utils.EstimateTokens(summary + "\n")
+	summaryTokens := tokens.Count(summary + "\n")
 	savedTokens := inputTokens - filteredTokens
 	if summaryTokens >= savedTokens {
 		return filtered
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"unicode/utf8"
@@ -14,26 +15,40 @@ import (
 
 // Registry of built-in actions.
 var actions = map[string]ActionFunc{
-	"keep_lines":      keepLines,
-	"remove_lines":    removeLines,
-	"truncate_lines":  truncateLines,
-	"truncate_bytes":  truncateBytes,
-	"strip_ansi":      stripANSI,
-	"head":            head,
-	"tail":            tail,
-	"group_by":        groupBy,
-	"dedup":           dedup,
-	"json_extract":    jsonExtract,
-	"json_schema":     jsonSchema,
-	"ndjson_stream":   ndjsonStream,
-	"regex_extract":   regexExtract,
-	"state_machine":   stateMachine,
-	"aggregate":       aggregate,
-	"format_template": formatTemplate,
-	"compact_path":    compactPath,
-	"replace":         replace,
-	"match_output":    matchOutput,
-	"on_empty":        onEmpty,
+	"keep_lines":        keepLines,
+	"remove_lines":      removeLines,
+	"context":           contextLines,
+	"truncate_lines":    truncateLines,
+	"truncate_bytes":    truncateBytes,
+	"strip_ansi":        stripANSI,
+	"collapse_progress": collapseProgress,
+	"head":              head,
+	"tail":              tail,
+	"sample":            sample,
+	"group_by":          groupBy,
+	"count_by":          countBy,
+	"sort":              sortLines,
+	"dedup":             dedup,
+	"json_extract":      jsonExtract,
+	"json_schema":       jsonSchema,
+	"json_query":        jsonQuery,
+	"yaml_extract":      yamlExtract,
+	"csv":               csvAction,
+	"gotest_json":       gotestJSON,
+	"ndjson_stream":     ndjsonStream,
+	"regex_extract":     regexExtract,
+	"state_machine":     stateMachine,
+	"aggregate":         aggregate,
+	"format_template":   formatTemplate,
+	"compact_path":      compactPath,
+	"path_tree":         pathTree,
+	"replace":           replace,
+	"match_output":      matchOutput,
+	"on_empty":          onEmpty,
+	"cluster":           cluster,
+	"budget":            budget,
+	"redact":            redact,
+	"junit":             junit,
 }
 
 // GetAction returns the ActionFunc for the given action name.
@@ -70,6 +85,23 @@ func getInt(params map[string]any, key string, def int) int {
 	}
 }
 
+func getFloat(params map[string]any, key string, def float64) float64 {
+	v, ok := params[key]
+	if !ok {
+		return def
+	}
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	default:
+		return def
+	}
+}
+
 func getBool(params map[string]any, key string) bool {
 	v, ok := params[key]
 	if !ok {
@@ -121,6 +153,60 @@ func removeLines(input ActionResult, params map[string]any) (ActionResult, error
 	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
 }
 
+// contextLines keeps matching lines plus up to before/after lines around
+// each, like grep -B/-A. Overlapping or adjacent windows merge, and a
+// separator marks each gap between windows. max_matches caps the number of
+// windows, with an overflow line counting the matches left out.
+func contextLines(input ActionResult, params map[string]any) (ActionResult, error) {
+	re, err := compilePattern(params, "pattern")
+	if err != nil {
+		return input, err
+	}
+	before := getInt(params, "before", 0)
+	after := getInt(params, "after", 0)
+	maxMatches := getInt(params, "max_matches", 0)
+	sep, ok := params["separator"].(string)
+	if !ok {
+		sep = "--"
+	}
+
+	type window struct{ start, end int }
+	var windows []window
+	skipped := 0
+	last := len(input.Lines) - 1
+	for i, line := range input.Lines {
+		if !re.MatchString(line) {
+			continue
+		}
+		start, end := max(i-before, 0), min(i+after, last)
+		if n := len(windows); n > 0 && start <= windows[n-1].end+1 {
+			windows[n-1].end = max(windows[n-1].end, end)
+			continue
+		}
+		if maxMatches > 0 && len(windows) >= maxMatches {
+			skipped++
+			continue
+		}
+		windows = append(windows, window{start, end})
+	}
+
+	var out []string
+	for i, w := range windows {
+		if i > 0 && sep != "" {
+			out = append(out, sep)
+		}
+		out = append(out, input.Lines[w.start:w.end+1]...)
+	}
+	if skipped > 0 {
+		msg := getStr(params, "overflow_msg")
+		if msg == "" {
+			msg = "+{skipped} more matches"
+		}
+		out = append(out, strings.ReplaceAll(msg, "{skipped}", strconv.Itoa(skipped)))
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
+
 func truncateLines(input ActionResult, params map[string]any) (ActionResult, error) {
 	max := getInt(params, "max", 80)
 	ellipsis := getStr(params, "ellipsis")
@@ -197,8 +283,9 @@ func head(input ActionResult, params map[string]any) (ActionResult, error) {
 	remaining := len(input.Lines) - n
 	msg := getStr(params, "overflow_msg")
 	if msg == "" {
-		msg = fmt.Sprintf("+%d more lines", remaining)
+		msg = "+{remaining} more lines"
 	}
+	msg = strings.ReplaceAll(msg, "{remaining}", strconv.Itoa(remaining))
 	out = append(out, msg)
 	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
 }
@@ -211,14 +298,70 @@ func tail(input ActionResult, params map[string]any) (ActionResult, error) {
 	dropped := len(input.Lines) - n
 	msg := getStr(params, "overflow_msg")
 	if msg == "" {
-		msg = fmt.Sprintf("+%d earlier lines", dropped)
+		msg = "+{dropped} earlier lines"
 	}
+	msg = strings.ReplaceAll(msg, "{dropped}", strconv.Itoa(dropped))
 	out := make([]string, 0, n+1)
 	out = append(out, msg)
 	out = append(out, input.Lines[dropped:]...)
 	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
 }
 
+// sample keeps the first 'head' and last 'tail' lines, where builds and test
+// runs put the first error and the final summary, and replaces the middle
+// with an omission marker. Middle lines matching 'always_keep' survive in
+// order, up to 'max_keep' of them, each splitting the gap it sits in.
+func sample(input ActionResult, params map[string]any) (ActionResult, error) {
+	h := getInt(params, "head", 10)
+	t := getInt(params, "tail", 10)
+	if h < 0 || t < 0 {
+		return input, fmt.Errorf("sample: 'head' and 'tail' must not be negative")
+	}
+	n := len(input.Lines)
+	if n <= h+t {
+		return input, nil
+	}
+	var keep *regexp.Regexp
+	if getStr(params, "always_keep") != "" {
+		re, err := compilePattern(params, "always_keep")
+		if err != nil {
+			return input, fmt.Errorf("sample: %w", err)
+		}
+		keep = re
+	}
+	maxKeep := getInt(params, "max_keep", 20)
+	marker := getStr(params, "marker")
+	if marker == "" {
+		marker = "… {n} lines omitted …"
+	}
+	omitted := func(k int) string {
+		return strings.ReplaceAll(marker, "{n}", strconv.Itoa(k))
+	}
+
+	// Built fresh, so appending never writes into the input's backing array.
+	out := make([]string, 0, h+t+1)
+	out = append(out, input.Lines[:h]...)
+	gap := 0
+	kept := 0
+	for _, line := range input.Lines[h : n-t] {
+		if keep != nil && (maxKeep <= 0 || kept < maxKeep) && keep.MatchString(line) {
+			if gap > 0 {
+				out = append(out, omitted(gap))
+				gap = 0
+			}
+			out = append(out, line)
+			kept++
+			continue
+		}
+		gap++
+	}
+	if gap > 0 {
+		out = append(out, omitted(gap))
+	}
+	out = append(out, input.Lines[n-t:]...)
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
+
 func groupBy(input ActionResult, params map[string]any) (ActionResult, error) {
 	re, err := compilePattern(params, "pattern")
 	if err != nil {
@@ -357,20 +500,22 @@ func jsonExtract(input ActionResult, params map[string]any) (ActionResult, error
 		return input, fmt.Errorf("json_extract: parse: %w", err)
 	}
 
+	extracted := make(map[string]any)
+	for _, f := range fields {
+		extracted[f] = data[f]
+	}
+	meta := setCapture(input.Metadata, params, extracted)
+
 	if fmtStr != "" {
 		tmpl, err := template.New("json").Parse(fmtStr)
 		if err != nil {
 			return input, err
 		}
-		extracted := make(map[string]any)
-		for _, f := range fields {
-			extracted[f] = data[f]
-		}
 		var buf strings.Builder
 		if err := tmpl.Execute(&buf, extracted); err != nil {
 			return input, fmt.Errorf("json_extract template: %w", err)
 		}
-		return ActionResult{Lines: strings.Split(buf.String(), "\n"), Metadata: input.Metadata}, nil
+		return ActionResult{Lines: strings.Split(buf.String(), "\n"), Metadata: meta}, nil
 	}
 
 	var out []string
@@ -379,7 +524,7 @@ func jsonExtract(input ActionResult, params map[string]any) (ActionResult, error
 			out = append(out, fmt.Sprintf("%s: %v", f, v))
 		}
 	}
-	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+	return ActionResult{Lines: out, Metadata: meta}, nil
 }
 
 func jsonSchema(input ActionResult, params map[string]any) (ActionResult, error) {
@@ -500,12 +645,22 @@ func regexExtract(input ActionResult, params map[string]any) (ActionResult, erro
 	}
 	fmtStr := getStr(params, "format")
 
+	// With capture_as, the named groups of the first match are captured.
+	var named map[string]string
 	var out []string
 	for _, line := range input.Lines {
 		m := re.FindStringSubmatch(line)
 		if m == nil {
 			continue
 		}
+		if named == nil {
+			named = make(map[string]string)
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					named[name] = m[i]
+				}
+			}
+		}
 		if fmtStr != "" {
 			result := fmtStr
 			for i, match := range m {
@@ -520,7 +675,11 @@ func regexExtract(input ActionResult, params map[string]any) (ActionResult, erro
 			}
 		}
 	}
-	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+	meta := input.Metadata
+	if len(named) > 0 {
+		meta = setCapture(meta, params, named)
+	}
+	return ActionResult{Lines: out, Metadata: meta}, nil
 }
 
 func stateMachine(input ActionResult, params map[string]any) (ActionResult, error) {
@@ -571,6 +730,9 @@ func stateMachine(input ActionResult, params map[string]any) (ActionResult, erro
 		}
 	}
 
+	// counts holds the lines read in each state, transitions excluded; it is
+	// what capture_as captures.
+	counts := make(map[string]int, len(states))
 	var out []string
 	for _, line := range input.Lines {
 		sc, ok := states[currentState]
@@ -584,13 +746,14 @@ func stateMachine(input ActionResult, params map[string]any) (ActionResult, erro
 			}
 			continue
 		}
+		counts[currentState]++
 		// Apply keep filter
 		if sc.keep == nil || sc.keep.MatchString(line) {
 			out = append(out, line)
 		}
 	}
 
-	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+	return ActionResult{Lines: out, Metadata: setCapture(input.Metadata, params, counts)}, nil
 }
 
 func aggregate(input ActionResult, params map[string]any) (ActionResult, error) {
@@ -649,8 +812,13 @@ func aggregate(input ActionResult, params map[string]any) (ActionResult, error)
 		if err != nil {
 			return input, err
 		}
+		// Pattern counts shadow metadata of the same name.
+		data := templateData(input.Metadata)
+		for k, v := range stats {
+			data[k] = v
+		}
 		var buf strings.Builder
-		if err := tmpl.Execute(&buf, stats); err != nil {
+		if err := tmpl.Execute(&buf, data); err != nil {
 			return input, fmt.Errorf("aggregate template: %w", err)
 		}
 		out = strings.Split(buf.String(), "\n")
@@ -658,6 +826,7 @@ func aggregate(input ActionResult, params map[string]any) (ActionResult, error)
 
 	meta := copyMeta(input.Metadata)
 	meta["stats"] = stats
+	meta = setCapture(meta, params, stats)
 
 	// append mode: keep original lines and add aggregate summary at the end
 	if getBool(params, "append") {
@@ -679,12 +848,9 @@ func formatTemplate(input ActionResult, params map[string]any) (ActionResult, er
 		return input, fmt.Errorf("format_template: %w", err)
 	}
 
-	data := map[string]any{
-		"lines":  strings.Join(input.Lines, "\n"),
-		"count":  len(input.Lines),
-		"groups": input.Metadata["groups"],
-		"stats":  input.Metadata["stats"],
-	}
+	data := templateData(input.Metadata)
+	data["lines"] = strings.Join(input.Lines, "\n")
+	data["count"] = len(input.Lines)
 
 	var buf strings.Builder
 	if err := tmpl.Execute(&buf, data); err != nil {
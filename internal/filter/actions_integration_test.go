@@ -61,17 +61,9 @@ func applyPipeline(f *Filter, input string) (string, error) {
 		Metadata: make(map[string]any),
 	}
 
-	for i, action := range f.Pipeline {
-		fn, ok := GetAction(action.ActionName)
-		if !ok {
-			return "", nil
-		}
-		var err error
-		result, err = fn(result, action.Params)
-		if err != nil {
-			return "", err
-		}
-		_ = i
+	result, err := RunPipeline(f.Pipeline, result)
+	if err != nil {
+		return "", err
 	}
 
 	return strings.Join(result.Lines, "\n") + "\n", nil
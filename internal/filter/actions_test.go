@@ -45,6 +45,40 @@ func TestRemoveLines(t *testing.T) {
 	}
 }
 
+func TestContextLines(t *testing.T) {
+	input := lines("a", "b", "error: one", "c", "d", "e", "f", "error: two", "g", "error: three", "h", "i")
+	res, err := contextLines(input, map[string]any{"pattern": `^error`, "before": 1, "after": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The windows around "two" and "three" overlap and merge; the gap before
+	// them gets a separator.
+	want := []string{"b", "error: one", "c", "--", "f", "error: two", "g", "error: three", "h"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestContextLinesMaxMatches(t *testing.T) {
+	input := lines("E1", "x", "x", "E2", "x", "x", "E3", "x", "x", "E4")
+	res, err := contextLines(input, map[string]any{"pattern": `^E`, "after": 1, "max_matches": 2, "separator": "..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"E1", "x", "...", "E2", "x", "+2 more matches"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+
+	res, err = contextLines(input, map[string]any{"pattern": `^E`, "max_matches": 1, "overflow_msg": "({skipped} errors hidden)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Lines[len(res.Lines)-1]; got != "(3 errors hidden)" {
+		t.Errorf("custom overflow msg: %q", got)
+	}
+}
+
 func TestTruncateLines(t *testing.T) {
 	input := lines("short", "this is a very long line that should be truncated at some point")
 	res, err := truncateLines(input, map[string]any{"max": 20, "ellipsis": "..."})
@@ -198,6 +232,24 @@ func TestHead(t *testing.T) {
 	}
 }
 
+func TestHeadTailOverflowPlaceholders(t *testing.T) {
+	input := lines("1", "2", "3", "4")
+	res, err := head(input, map[string]any{"n": 1, "overflow_msg": "... {remaining} more errors"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Lines[len(res.Lines)-1]; got != "... 3 more errors" {
+		t.Errorf("head overflow msg: %q", got)
+	}
+	res, err = tail(input, map[string]any{"n": 1, "overflow_msg": "({dropped} skipped)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Lines[0] != "(3 skipped)" {
+		t.Errorf("tail overflow msg: %q", res.Lines[0])
+	}
+}
+
 func TestHeadNoOverflow(t *testing.T) {
 	input := lines("1", "2", "3")
 	res, err := head(input, map[string]any{"n": 5})
@@ -238,6 +290,54 @@ func TestTailNoOverflow(t *testing.T) {
 	}
 }
 
+func TestSample(t *testing.T) {
+	input := lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10")
+	res, err := sample(input, map[string]any{"head": 2, "tail": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2", "… 5 lines omitted …", "8", "9", "10"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+
+	res, err = sample(input, map[string]any{"head": 5, "tail": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 10 {
+		t.Errorf("short input should pass through, got %q", res.Lines)
+	}
+}
+
+func TestSampleAlwaysKeep(t *testing.T) {
+	input := lines("start", "a", "error: one", "b", "c", "error: two", "error: three", "d", "end")
+	res, err := sample(input, map[string]any{"head": 1, "tail": 1, "always_keep": "^error", "max_keep": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start", "… 1 lines omitted …", "error: one", "… 2 lines omitted …", "error: two", "… 2 lines omitted …", "end"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+// TestSampleDoesNotAliasInput guards the append-safety convention: the
+// output must not share a backing array with the input lines.
+func TestSampleDoesNotAliasInput(t *testing.T) {
+	backing := make([]string, 6, 20)
+	copy(backing, []string{"1", "2", "3", "4", "5", "6"})
+	input := ActionResult{Lines: backing, Metadata: map[string]any{}}
+	res, err := sample(input, map[string]any{"head": 2, "tail": 1, "marker": "gap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Lines[0] = "changed"
+	if backing[0] != "1" || backing[2] != "3" {
+		t.Errorf("input mutated: %q", backing)
+	}
+}
+
 func TestTailCustomOverflowMsg(t *testing.T) {
 	input := lines("1", "2", "3", "4", "5")
 	res, err := tail(input, map[string]any{"n": 2, "overflow_msg": "[...]"})
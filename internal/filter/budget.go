@@ -0,0 +1,140 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edouard-claude/snip/internal/tokens"
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// defaultBudgetPriorities rank lines when a budget action sets no priorities:
+// errors first, then warnings, then file:line references. Everything else is
+// the lowest tier and the first to go.
+var defaultBudgetPriorities = []*utils.LazyRegex{
+	utils.NewLazyRegex(`(?i)\b(error|errors|fail|failed|failure|panic|fatal|exception)\b`),
+	utils.NewLazyRegex(`(?i)\bwarn(ing)?s?\b`),
+	utils.NewLazyRegex(`[\w./-]+\.\w+:\d+`),
+}
+
+// budget trims the output to a target token count by dropping the lowest
+// priority lines first, unlike head, tail and truncate_bytes which cut by
+// position. Each run of dropped lines is replaced by one elision marker, and
+// the markers are paid for out of the budget.
+func budget(input ActionResult, params map[string]any) (ActionResult, error) {
+	target := getInt(params, "tokens", 0)
+	if target <= 0 {
+		return input, fmt.Errorf("budget: 'tokens' must be a positive integer")
+	}
+
+	var tiers []*regexp.Regexp
+	if raw, ok := params["priorities"]; ok {
+		patterns, ok := toStringSlice(raw)
+		if !ok {
+			return input, fmt.Errorf("budget: 'priorities' must be a list of strings")
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return input, fmt.Errorf("budget priority %q: %w", p, err)
+			}
+			tiers = append(tiers, re)
+		}
+	} else {
+		for _, lr := range defaultBudgetPriorities {
+			tiers = append(tiers, lr.Re())
+		}
+	}
+
+	marker := getStr(params, "marker")
+	if marker == "" {
+		marker = "... {n} lines omitted"
+	}
+
+	n := len(input.Lines)
+	cost := make([]int, n)
+	total := 0
+	for i, line := range input.Lines {
+		cost[i] = tokens.Count(line + "\n")
+		total += cost[i]
+	}
+	if total <= target {
+		return input, nil
+	}
+
+	// A line's rank is the index of the first tier it matches; unmatched lines
+	// rank below every tier.
+	rank := make([]int, n)
+	for i, line := range input.Lines {
+		rank[i] = len(tiers)
+		for t, re := range tiers {
+			if re.MatchString(line) {
+				rank[i] = t
+				break
+			}
+		}
+	}
+
+	// Drop order: lowest tier first. Within a tier, lines nearest the middle
+	// go first, so the opening and closing lines of a build or test run, where
+	// the first error and the final summary live, survive the longest.
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	mid := float64(n-1) / 2
+	dist := func(i int) float64 {
+		d := float64(i) - mid
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ia, ib := order[a], order[b]
+		if rank[ia] != rank[ib] {
+			return rank[ia] > rank[ib]
+		}
+		return dist(ia) < dist(ib)
+	})
+
+	// Marker cost is estimated with the widest count it could carry, which
+	// overestimates slightly and so never lets the result exceed the target.
+	markerCost := tokens.Count(strings.ReplaceAll(marker, "{n}", strconv.Itoa(n)) + "\n")
+	dropped := make([]bool, n)
+	for _, i := range order {
+		if total <= target {
+			break
+		}
+		left := i > 0 && dropped[i-1]
+		right := i < n-1 && dropped[i+1]
+		dropped[i] = true
+		total -= cost[i]
+		switch {
+		case left && right:
+			total -= markerCost // two runs merge into one
+		case !left && !right:
+			total += markerCost // a new run starts
+		}
+	}
+
+	var out []string
+	for i := 0; i < n; {
+		if !dropped[i] {
+			out = append(out, input.Lines[i])
+			i++
+			continue
+		}
+		j := i
+		for j < n && dropped[j] {
+			j++
+		}
+		out = append(out, strings.ReplaceAll(marker, "{n}", strconv.Itoa(j-i)))
+		i = j
+	}
+
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
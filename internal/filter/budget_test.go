@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBudgetUnderTargetIsNoOp(t *testing.T) {
+	input := lines("a", "b", "c")
+	res, err := budget(input, map[string]any{"tokens": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(res.Lines, "\n") != "a\nb\nc" {
+		t.Errorf("got %q", res.Lines)
+	}
+}
+
+func TestBudgetKeepsHighPriorityLines(t *testing.T) {
+	var in []string
+	in = append(in, "Compiling app v0.1.0")
+	for i := 0; i < 40; i++ {
+		in = append(in, fmt.Sprintf("   Compiling dep-%02d v1.0.%d", i, i))
+	}
+	in = append(in, "error[E0308]: mismatched types")
+	in = append(in, " --> src/main.rs:4:18")
+	for i := 0; i < 40; i++ {
+		in = append(in, fmt.Sprintf("   Compiling late-%02d v2.0.%d", i, i))
+	}
+	in = append(in, "warning: unused variable `x`")
+	in = append(in, "build finished")
+
+	res, err := budget(lines(in...), map[string]any{"tokens": 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(res.Lines, "\n")
+	for _, want := range []string{"error[E0308]: mismatched types", " --> src/main.rs:4:18", "warning: unused variable `x`"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("high-priority line %q dropped:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, "lines omitted") {
+		t.Errorf("missing elision marker:\n%s", out)
+	}
+	// Opening and closing lines outlive the middle of their tier.
+	if res.Lines[0] != "Compiling app v0.1.0" || res.Lines[len(res.Lines)-1] != "build finished" {
+		t.Errorf("ends not preserved:\n%s", out)
+	}
+	if got := estimate(res.Lines); got > 60 {
+		t.Errorf("output is %d tokens, over the 60-token budget:\n%s", got, out)
+	}
+}
+
+func TestBudgetCustomPrioritiesAndMarker(t *testing.T) {
+	input := lines("keep 1", "noise", "noise", "keep 2", "noise")
+	res, err := budget(input, map[string]any{
+		"tokens":     8,
+		"priorities": []any{`^keep`},
+		"marker":     "[{n} cut]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Noise nearest the middle goes first; once that run is cut the output
+	// fits, so the trailing "noise" survives.
+	want := []string{"keep 1", "[2 cut]", "keep 2", "noise"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestBudgetInvalidParams(t *testing.T) {
+	if _, err := budget(lines("a"), map[string]any{}); err == nil {
+		t.Error("expected error for missing tokens")
+	}
+	if _, err := budget(lines("a"), map[string]any{"tokens": 1, "priorities": []any{"("}}); err == nil {
+		t.Error("expected error for invalid priority regex")
+	}
+}
+
+func estimate(ls []string) int {
+	total := 0
+	for _, l := range ls {
+		total += (len(l) + 1 + 3) / 4
+	}
+	return total
+}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// RunKey is the metadata key holding the RunContext of the command whose
+// output is being filtered.
+const RunKey = "run"
+
+// RunContext describes the command run. Templates read it as .run, e.g.
+// "{{.run.ExitCode}}" or "{{.run.Duration}}".
+type RunContext struct {
+	ExitCode int
+	Duration time.Duration
+	Cwd      string
+	// Argv is the command and the arguments it ran with, injected ones
+	// included.
+	Argv []string
+}
+
+// captureValueKey is where an action that supports capture_as leaves a
+// structured value to capture instead of its output text. RunPipeline moves
+// it under the capture name, so it never reaches a template.
+const captureValueKey = "\x00capture"
+
+// reservedCaptures are the template names capture_as cannot take.
+var reservedCaptures = map[string]bool{"lines": true, "count": true, RunKey: true}
+
+var captureNameRe = utils.NewLazyRegex(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateCaptureAs checks the capture_as param every action accepts: the
+// name must be usable as "{{.name}}" and not hide a built-in template value.
+func validateCaptureAs(params map[string]any) error {
+	raw, ok := params["capture_as"]
+	if !ok {
+		return nil
+	}
+	name, ok := raw.(string)
+	if !ok || !captureNameRe.Re().MatchString(name) {
+		return fmt.Errorf("'capture_as' must be an identifier, got %v", raw)
+	}
+	if reservedCaptures[name] {
+		return fmt.Errorf("'capture_as' cannot be %q, it is reserved", name)
+	}
+	return nil
+}
+
+// setCapture records v as the value capture_as captures from an action,
+// when the action is capturing. It returns meta, copied if it was changed.
+func setCapture(meta, params map[string]any, v any) map[string]any {
+	if getStr(params, "capture_as") == "" {
+		return meta
+	}
+	meta = copyMeta(meta)
+	meta[captureValueKey] = v
+	return meta
+}
+
+// RunPipeline applies the actions of p in order. An action with capture_as
+// runs for its value only: its output (the structured value it records with
+// setCapture, else its output text) is stored in the metadata under that
+// name, and the next action sees the lines it was given.
+func RunPipeline(p Pipeline, result ActionResult) (ActionResult, error) {
+	for i, action := range p {
+		fn, ok := GetAction(action.ActionName)
+		if !ok {
+			return result, fmt.Errorf("unknown action %q at pipeline[%d]", action.ActionName, i)
+		}
+		name := getStr(action.Params, "capture_as")
+		if name == "" {
+			var err error
+			result, err = fn(result, action.Params)
+			if err != nil {
+				return result, fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
+			}
+			continue
+		}
+		// The action gets its own copy of the lines, so one that edits its
+		// input in place cannot change the lines that flow on.
+		res, err := fn(ActionResult{Lines: slices.Clone(result.Lines), Metadata: result.Metadata}, action.Params)
+		if err != nil {
+			return result, fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
+		}
+		v, ok := res.Metadata[captureValueKey]
+		if !ok {
+			v = strings.Join(res.Lines, "\n")
+		}
+		meta := copyMeta(result.Metadata)
+		meta[name] = v
+		result = ActionResult{Lines: result.Lines, Metadata: meta}
+	}
+	return result, nil
+}
+
+// templateData is what format_template and aggregate's format see: every
+// metadata value (groups, stats, captures, run, ...) by its key.
+func templateData(meta map[string]any) map[string]any {
+	data := make(map[string]any, len(meta)+2)
+	for k, v := range meta {
+		if k != captureValueKey {
+			data[k] = v
+		}
+	}
+	return data
+}
@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPipelineCaptureKeepsLines(t *testing.T) {
+	p := Pipeline{
+		{ActionName: "keep_lines", Params: map[string]any{"pattern": "^a", "capture_as": "first"}},
+		{ActionName: "format_template", Params: map[string]any{"template": "{{.first}} of {{.count}}"}},
+	}
+	res, err := RunPipeline(p, lines("a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a of 3"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestRunPipelineCaptureStructured(t *testing.T) {
+	p := Pipeline{
+		{ActionName: "regex_extract", Params: map[string]any{
+			"pattern":    `(?P<passed>\d+) passed, (?P<failed>\d+) failed`,
+			"capture_as": "result",
+		}},
+		{ActionName: "state_machine", Params: map[string]any{
+			"states": map[string]any{
+				"start":   map[string]any{"until": "^--- FAIL", "next": "failing"},
+				"failing": map[string]any{"keep": "."},
+			},
+			"capture_as": "states",
+		}},
+		{ActionName: "aggregate", Params: map[string]any{
+			"patterns":   map[string]any{"fail": "^--- FAIL"},
+			"capture_as": "stats_only",
+		}},
+		{ActionName: "format_template", Params: map[string]any{
+			"template": "{{.result.passed}}/{{.result.failed}} {{.states.start}}+{{.states.failing}} {{.stats_only.fail}} {{.count}}",
+		}},
+	}
+	in := lines("=== RUN x", "ok", "--- FAIL y", "boom", "3 passed, 1 failed")
+	res, err := RunPipeline(p, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"3/1 2+2 1 5"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+	if _, ok := res.Metadata["stats"]; ok {
+		t.Error("a capturing action's own metadata leaked into the pipeline")
+	}
+}
+
+func TestJSONExtractCapture(t *testing.T) {
+	p := Pipeline{
+		{ActionName: "json_extract", Params: map[string]any{"fields": []any{"name", "version"}, "capture_as": "pkg"}},
+		{ActionName: "format_template", Params: map[string]any{"template": "{{.pkg.name}}@{{.pkg.version}}"}},
+	}
+	res, err := RunPipeline(p, lines(`{"name": "snip", "version": "1.2.0", "private": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"snip@1.2.0"}; !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestTemplatesSeeRunContext(t *testing.T) {
+	in := lines("ok")
+	in.Metadata[RunKey] = RunContext{ExitCode: 2, Duration: 1500 * time.Millisecond, Cwd: "/src", Argv: []string{"go", "test"}}
+
+	res, err := formatTemplate(in, map[string]any{
+		"template": `exit {{.run.ExitCode}} in {{.run.Duration}} ({{index .run.Argv 0}}, {{.run.Cwd}})`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "exit 2 in 1.5s (go, /src)"; res.Lines[0] != want {
+		t.Errorf("format_template: got %q, want %q", res.Lines[0], want)
+	}
+
+	res, err = aggregate(in, map[string]any{
+		"patterns": map[string]any{"ok": "^ok$"},
+		"format":   "{{.ok}} ok, exit {{.run.ExitCode}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 ok, exit 2"; res.Lines[0] != want {
+		t.Errorf("aggregate: got %q, want %q", res.Lines[0], want)
+	}
+}
+
+func TestValidateCaptureAs(t *testing.T) {
+	for _, name := range []any{"", "1st", "a-b", "lines", "run", 3} {
+		err := ValidateFilter(&Filter{
+			Name:     "t",
+			Match:    Match{Command: "t"},
+			Pipeline: Pipeline{{ActionName: "head", Params: map[string]any{"capture_as": name}}},
+		})
+		if err == nil || !strings.Contains(err.Error(), "capture_as") {
+			t.Errorf("capture_as %v: got %v, want a capture_as error", name, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// clusterWildcard replaces the tokens that vary between lines of a template.
+const clusterWildcard = "<*>"
+
+// clusterMasks are the variable fields masked before tokens are compared, in
+// the spirit of Drain's preprocessing step. Without them two lines that differ
+// only in a timestamp would need that token to be wildcarded by similarity,
+// which fails for short lines where one token is a large share of the whole.
+var clusterMasks = []*utils.LazyRegex{
+	utils.NewLazyRegex(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+	utils.NewLazyRegex(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+	utils.NewLazyRegex(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`),
+	utils.NewLazyRegex(`\b0x[0-9a-fA-F]+\b`),
+	utils.NewLazyRegex(`\b[0-9a-fA-F]{12,}\b`),
+}
+
+type logCluster struct {
+	tokens  []string
+	example string
+	lines   []string
+}
+
+// similarity returns the share of positions where the template and the line
+// carry the same literal token. Wildcard positions do not count as a match, so
+// a template that is mostly wildcards cannot swallow unrelated lines.
+func (c *logCluster) similarity(tokens []string) float64 {
+	same := 0
+	for i, tok := range tokens {
+		if c.tokens[i] != clusterWildcard && c.tokens[i] == tok {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
+
+// newLogCluster starts a template from its first line, on a fresh slice the
+// cluster owns, so merge never appends into the input.
+func newLogCluster(tokens []string, line string) *logCluster {
+	return &logCluster{tokens: tokens, example: line, lines: append(make([]string, 0, 4), line)}
+}
+
+func (c *logCluster) merge(tokens []string, line string) {
+	for i, tok := range tokens {
+		if c.tokens[i] != tok {
+			c.tokens[i] = clusterWildcard
+		}
+	}
+	c.lines = append(c.lines, line)
+}
+
+// cluster implements online log-template mining after Drain (He et al., 2017).
+// Lines are tokenized on whitespace, routed by token count and leading token,
+// and merged into the most similar template when it reaches the threshold.
+// Tokens that differ within a template become <*>. Templates seen fewer than
+// min_count times are emitted as their original lines.
+func cluster(input ActionResult, params map[string]any) (ActionResult, error) {
+	threshold := getFloat(params, "threshold", 0.5)
+	if threshold <= 0 || threshold > 1 {
+		return input, fmt.Errorf("cluster: 'threshold' must be in (0, 1], got %v", threshold)
+	}
+	maxClusters := getInt(params, "max_clusters", 100)
+	minCount := getInt(params, "min_count", 2)
+	fmtStr := getStr(params, "format")
+	if fmtStr == "" {
+		fmtStr = "{{.Template}} (x{{.Count}}) e.g. {{.Example}}"
+	}
+	tmpl, err := template.New("cluster").Parse(fmtStr)
+	if err != nil {
+		return input, fmt.Errorf("cluster format: %w", err)
+	}
+
+	var masks []*regexp.Regexp
+	for _, lr := range clusterMasks {
+		masks = append(masks, lr.Re())
+	}
+	if raw, ok := params["mask"]; ok {
+		extra, ok := toStringSlice(raw)
+		if !ok {
+			return input, fmt.Errorf("cluster: 'mask' must be a list of strings")
+		}
+		for _, p := range extra {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return input, fmt.Errorf("cluster mask %q: %w", p, err)
+			}
+			masks = append(masks, re)
+		}
+	}
+
+	// An output slot is either a cluster (emitted at its first line's position)
+	// or a verbatim line that matched nothing once max_clusters was reached.
+	type slot struct {
+		c    *logCluster
+		line string
+	}
+
+	groups := make(map[string][]*logCluster) // key = token count + leading token
+	var slots []slot
+	total := 0
+
+	for _, line := range input.Lines {
+		masked := line
+		for _, re := range masks {
+			masked = re.ReplaceAllString(masked, clusterWildcard)
+		}
+		tokens := strings.Fields(masked)
+		if len(tokens) == 0 {
+			slots = append(slots, slot{line: line})
+			continue
+		}
+
+		// Drain routes on the first token, but a token carrying a digit is
+		// likely a variable and would scatter one template across many keys.
+		lead := tokens[0]
+		if strings.ContainsAny(lead, "0123456789") {
+			lead = clusterWildcard
+		}
+		key := fmt.Sprintf("%d %s", len(tokens), lead)
+
+		var best *logCluster
+		bestSim := -1.0
+		for _, c := range groups[key] {
+			if sim := c.similarity(tokens); sim > bestSim {
+				best, bestSim = c, sim
+			}
+		}
+
+		switch {
+		case best != nil && bestSim >= threshold:
+			best.merge(tokens, line)
+		case maxClusters <= 0 || total < maxClusters:
+			c := newLogCluster(tokens, line)
+			groups[key] = append(groups[key], c)
+			slots = append(slots, slot{c: c})
+			total++
+		default:
+			slots = append(slots, slot{line: line})
+		}
+	}
+
+	var out []string
+	for _, s := range slots {
+		if s.c == nil {
+			out = append(out, s.line)
+			continue
+		}
+		if len(s.c.lines) < minCount {
+			out = append(out, s.c.lines...)
+			continue
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, map[string]any{
+			"Template": strings.Join(s.c.tokens, " "),
+			"Count":    len(s.c.lines),
+			"Example":  s.c.example,
+		}); err != nil {
+			return input, fmt.Errorf("cluster template: %w", err)
+		}
+		out = append(out, buf.String())
+	}
+
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
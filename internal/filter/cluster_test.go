@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	var in []string
+	for i := 0; i < 50; i++ {
+		in = append(in, fmt.Sprintf("2024-05-01T10:00:%02dZ GET /api/users/%d 200 in %dms from 10.0.0.%d", i, i*7, i+3, i%9))
+	}
+	in = append(in, "panic: runtime error: index out of range")
+	for i := 0; i < 3; i++ {
+		in = append(in, fmt.Sprintf("worker %d shutting down", i))
+	}
+
+	res, err := cluster(lines(in...), map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"<*> GET <*> 200 in <*> from <*> (x50) e.g. " + in[0],
+		"panic: runtime error: index out of range",
+		"worker <*> shutting down (x3) e.g. worker 0 shutting down",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestClusterKeepsDistinctTemplatesApart(t *testing.T) {
+	input := lines(
+		"connected to db primary",
+		"connected to db replica",
+		"disk usage high on /var",
+		"cache miss for key users",
+	)
+	res, err := cluster(input, map[string]any{"threshold": 0.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"connected to db <*> (x2) e.g. connected to db primary",
+		"disk usage high on /var",
+		"cache miss for key users",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestClusterMaxClusters(t *testing.T) {
+	input := lines("alpha one", "beta two", "gamma three", "alpha one")
+	res, err := cluster(input, map[string]any{"max_clusters": 1, "format": "{{.Template}} x{{.Count}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Once the cap is reached, unmatched lines are kept verbatim in place.
+	want := []string{"alpha one x2", "beta two", "gamma three"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestClusterInvalidParams(t *testing.T) {
+	if _, err := cluster(lines("a"), map[string]any{"threshold": 1.5}); err == nil {
+		t.Error("expected error for threshold > 1")
+	}
+	if _, err := cluster(lines("a"), map[string]any{"mask": []any{"("}}); err == nil {
+		t.Error("expected error for invalid mask regex")
+	}
+}
@@ -0,0 +1,337 @@
+package filter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+var (
+	// psqlSeparatorRe is the rule psql prints under the header of an aligned
+	// table: "----+-----".
+	psqlSeparatorRe = utils.NewLazyRegex(`^-+(\+-+)*$`)
+	// psqlFooterRe is the row count psql prints after a table.
+	psqlFooterRe = utils.NewLazyRegex(`^\((\d+) rows?\)$`)
+)
+
+// csvTable is one parsed table. before holds lines that preceded it and are
+// kept verbatim (a psql title, command tags); total is the row count the
+// tool reported, or the number of rows parsed. A numbered table had no header
+// row: its columns are named 1, 2, ... and no header is rendered.
+type csvTable struct {
+	before   []string
+	header   []string
+	rows     [][]string
+	total    int
+	numbered bool
+}
+
+// csvOptions holds the csv action's params.
+type csvOptions struct {
+	columns     []string
+	rename      map[string]string
+	maxRows     int
+	maxWidth    int
+	overflowMsg string
+}
+
+// csvAction parses delimited output (CSV, TSV, or psql's aligned tables),
+// keeps and renames the requested columns, and re-renders it as a compact
+// table: cells padded to the column width, numeric columns right-aligned,
+// wide cells truncated. Rows beyond max_rows are replaced by a marker that
+// gives the total. Input with no table in it, such as psql's command tags
+// after an INSERT, passes through unchanged.
+func csvAction(input ActionResult, params map[string]any) (ActionResult, error) {
+	opts, err := parseCSVOptions(params)
+	if err != nil {
+		return input, fmt.Errorf("csv: %w", err)
+	}
+
+	format := getStr(params, "format")
+	var tables []csvTable
+	var after []string
+	switch format {
+	case "", "csv", "tsv":
+		comma := ','
+		if format == "tsv" {
+			comma = '\t'
+		}
+		if d := getStr(params, "delimiter"); d != "" {
+			r, size := utf8.DecodeRuneInString(d)
+			if size != len(d) {
+				return input, fmt.Errorf("csv: 'delimiter' must be a single character")
+			}
+			comma = r
+		}
+		header := true
+		if v, ok := params["header"].(bool); ok {
+			header = v
+		}
+		t, ok, err := parseDelimited(input.Lines, comma, header)
+		if err != nil {
+			return input, fmt.Errorf("csv: %w", err)
+		}
+		if ok {
+			tables = []csvTable{t}
+		}
+	case "psql":
+		tables, after = parsePsql(input.Lines)
+	default:
+		return input, fmt.Errorf("csv: 'format' must be csv, tsv or psql, got %q", format)
+	}
+	if len(tables) == 0 {
+		return input, nil
+	}
+
+	var out []string
+	for _, t := range tables {
+		rendered, err := opts.render(t)
+		if err != nil {
+			return input, fmt.Errorf("csv: %w", err)
+		}
+		out = append(out, t.before...)
+		out = append(out, rendered...)
+	}
+	out = append(out, after...)
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
+
+func parseCSVOptions(params map[string]any) (*csvOptions, error) {
+	opts := &csvOptions{
+		maxRows:     getInt(params, "max_rows", 20),
+		maxWidth:    getInt(params, "max_width", 40),
+		overflowMsg: getStr(params, "overflow_msg"),
+	}
+	if opts.overflowMsg == "" {
+		opts.overflowMsg = "+{n} more rows ({total} total)"
+	}
+	if raw, ok := params["columns"]; ok {
+		list, ok := raw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("'columns' must be a list of column names or 1-based indexes")
+		}
+		opts.columns = make([]string, 0, len(list))
+		for _, c := range list {
+			switch v := c.(type) {
+			case string:
+				opts.columns = append(opts.columns, v)
+			case int:
+				opts.columns = append(opts.columns, strconv.Itoa(v))
+			default:
+				return nil, fmt.Errorf("'columns' must be a list of column names or 1-based indexes")
+			}
+		}
+	}
+	if raw, ok := params["rename"]; ok {
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("'rename' must be a map of column name to new name")
+		}
+		opts.rename = make(map[string]string, len(m))
+		for k, v := range m {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("rename %q: new name must be a string", k)
+			}
+			opts.rename[k] = s
+		}
+	}
+	return opts, nil
+}
+
+// parseDelimited reads RFC 4180 records, reporting false when there are none.
+// Without a header row the columns are named by their 1-based index.
+func parseDelimited(lines []string, comma rune, header bool) (csvTable, bool, error) {
+	r := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var records [][]string
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return csvTable{}, false, err
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return csvTable{}, false, nil
+	}
+
+	var t csvTable
+	if header {
+		t.header, records = records[0], records[1:]
+	} else {
+		width := 0
+		for _, rec := range records {
+			width = max(width, len(rec))
+		}
+		t.header = make([]string, width)
+		for i := range t.header {
+			t.header[i] = strconv.Itoa(i + 1)
+		}
+		t.numbered = true
+	}
+	t.rows = records
+	t.total = len(records)
+	return t, true, nil
+}
+
+// parsePsql finds psql's aligned tables: a header line above a separator
+// rule, then "a | b" rows up to a "(N rows)" footer, which is dropped, or a
+// line that is not a row. Other lines are kept in place; lines after the last
+// table are returned apart.
+func parsePsql(lines []string) ([]csvTable, []string) {
+	var tables []csvTable
+	var pending []string
+	for i := 0; i < len(lines); i++ {
+		if i+1 >= len(lines) || !psqlSeparatorRe.Re().MatchString(strings.TrimSpace(lines[i+1])) {
+			pending = append(pending, lines[i])
+			continue
+		}
+		t := csvTable{before: pending, header: splitPsqlRow(lines[i])}
+		pending = nil
+		var rows [][]string
+		i += 2
+		for ; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if m := psqlFooterRe.Re().FindStringSubmatch(line); m != nil {
+				t.total, _ = strconv.Atoi(m[1])
+				break
+			}
+			// \d output goes straight on to "Indexes:" and the like.
+			if line == "" || (len(t.header) > 1 && !strings.Contains(line, "|")) {
+				i--
+				break
+			}
+			rows = append(rows, splitPsqlRow(lines[i]))
+		}
+		t.rows = rows
+		if t.total == 0 {
+			t.total = len(t.rows)
+		}
+		tables = append(tables, t)
+	}
+	return tables, pending
+}
+
+func splitPsqlRow(line string) []string {
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+// render selects, renames and aligns the columns of t.
+func (o *csvOptions) render(t csvTable) ([]string, error) {
+	idx := make([]int, 0, len(t.header))
+	if len(o.columns) == 0 {
+		for i := range t.header {
+			idx = append(idx, i)
+		}
+	}
+	for _, col := range o.columns {
+		i, err := columnIndex(t.header, col)
+		if err != nil {
+			return nil, err
+		}
+		idx = append(idx, i)
+	}
+
+	rows := t.rows
+	if o.maxRows > 0 && len(rows) > o.maxRows {
+		rows = rows[:o.maxRows]
+	}
+	grid := make([][]string, 0, len(rows)+1)
+	if !t.numbered {
+		head := make([]string, len(idx))
+		for j, i := range idx {
+			head[j] = t.header[i]
+			if name, ok := o.rename[head[j]]; ok {
+				head[j] = name
+			}
+			head[j] = o.cell(head[j])
+		}
+		grid = append(grid, head)
+	}
+	// body is the first data row of grid.
+	body := len(grid)
+	for _, row := range rows {
+		cells := make([]string, len(idx))
+		for j, i := range idx {
+			if i < len(row) {
+				cells[j] = o.cell(row[i])
+			}
+		}
+		grid = append(grid, cells)
+	}
+
+	widths := make([]int, len(idx))
+	numeric := make([]bool, len(idx))
+	for j := range idx {
+		numeric[j] = len(grid) > body
+		for r, cells := range grid {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cells[j]))
+			if r >= body && cells[j] != "" {
+				if _, err := strconv.ParseFloat(cells[j], 64); err != nil {
+					numeric[j] = false
+				}
+			}
+		}
+	}
+
+	out := make([]string, 0, len(grid)+1)
+	for r, cells := range grid {
+		var b strings.Builder
+		for j, c := range cells {
+			if j > 0 {
+				b.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(c))
+			if numeric[j] && r >= body {
+				b.WriteString(pad + c)
+			} else {
+				b.WriteString(c + pad)
+			}
+		}
+		out = append(out, strings.TrimRight(b.String(), " "))
+	}
+	if len(rows) < len(t.rows) {
+		msg := strings.ReplaceAll(o.overflowMsg, "{n}", strconv.Itoa(t.total-len(rows)))
+		out = append(out, strings.ReplaceAll(msg, "{total}", strconv.Itoa(t.total)))
+	}
+	return out, nil
+}
+
+// cell flattens embedded newlines and truncates to max_width runes.
+func (o *csvOptions) cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if o.maxWidth > 0 && utf8.RuneCountInString(s) > o.maxWidth {
+		r := []rune(s)
+		s = string(r[:max(o.maxWidth-1, 0)]) + "…"
+	}
+	return s
+}
+
+// columnIndex resolves a column by header name, or by 1-based index.
+func columnIndex(header []string, col string) (int, error) {
+	for i, h := range header {
+		if h == col {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(col); err == nil && n >= 1 && n <= len(header) {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("unknown column %q", col)
+}
@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCSVSelectRenameAndAlign(t *testing.T) {
+	input := lines(
+		"id,name,size,note",
+		`1,alpha,100,"has, comma"`,
+		`2,beta,7,"multi`,
+		`line"`,
+	)
+	res, err := csvAction(input, map[string]any{
+		"columns": []any{"name", 3, "note"},
+		"rename":  map[string]any{"size": "bytes"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"name   bytes  note",
+		"alpha    100  has, comma",
+		"beta       7  multi line",
+	}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCSVMaxRowsAndWidth(t *testing.T) {
+	input := lines("k\tv", "a\tabcdefghij", "b\tx", "c\ty")
+	res, err := csvAction(input, map[string]any{"format": "tsv", "max_rows": 2, "max_width": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"k  v", "a  abcd…", "b  x", "+1 more rows (3 total)"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCSVNoHeader(t *testing.T) {
+	res, err := csvAction(lines("a|1", "b|2"), map[string]any{"delimiter": "|", "header": false, "columns": []any{"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1", "2"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCSVPsqlAligned(t *testing.T) {
+	input := lines(
+		"                 List of things",
+		" id |  name  | descr ",
+		"----+--------+-------",
+		"  1 | alpha  | first",
+		" 12 | beta   | ",
+		"(2 rows)",
+		"",
+		"INSERT 0 1",
+	)
+	res, err := csvAction(input, map[string]any{"format": "psql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"                 List of things",
+		"id  name   descr",
+		" 1  alpha  first",
+		"12  beta",
+		"",
+		"INSERT 0 1",
+	}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCSVErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  ActionResult
+		params map[string]any
+	}{
+		{"unknown column", lines("a,b", "1,2"), map[string]any{"columns": []any{"c"}}},
+		{"unknown format", lines("a"), map[string]any{"format": "xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := csvAction(tt.input, tt.params); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestCSVNoTablePassesThrough verifies that output with no table, such as
+// psql's command tags, is kept as is rather than failing the filter.
+func TestCSVNoTablePassesThrough(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  ActionResult
+		params map[string]any
+	}{
+		{"psql command tag", lines("INSERT 0 1"), map[string]any{"format": "psql"}},
+		{"psql no relations", lines("Did not find any relations."), map[string]any{"format": "psql"}},
+		{"empty csv", lines(), map[string]any{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := csvAction(tt.input, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Lines, tt.input.Lines) {
+				t.Errorf("got %q, want %q", res.Lines, tt.input.Lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// testEvent is one line of `go test -json` (test2json) output. Build events
+// (Go 1.24+) carry ImportPath instead of Package.
+type testEvent struct {
+	Action     string `json:"Action"`
+	Package    string `json:"Package"`
+	ImportPath string `json:"ImportPath"`
+	Test       string `json:"Test"`
+	Output     string `json:"Output"`
+}
+
+// isTestNoise reports whether an output line restates what the event stream
+// already says: test framing, per-test verdicts and package verdicts.
+func isTestNoise(line string) bool {
+	t := strings.TrimSpace(line)
+	if t == "PASS" || t == "FAIL" {
+		return true
+	}
+	for _, p := range []string{"=== RUN", "=== PAUSE", "=== CONT", "=== NAME", "--- PASS", "--- FAIL", "--- SKIP"} {
+		if strings.HasPrefix(t, p) {
+			return true
+		}
+	}
+	return strings.HasPrefix(line, "ok  \t") || strings.HasPrefix(line, "?   \t") || strings.HasPrefix(line, "FAIL\t")
+}
+
+func testKey(pkg, test string) string { return pkg + "\x00" + test }
+
+// gotestJSON reduces a `go test -json` event stream to a totals line, then
+// every failed test with the output it captured (capped at max_output lines),
+// then packages that failed without a failing test, such as build failures.
+// Lines that are not test2json events are kept verbatim.
+//
+// Totals count leaf tests: a test with subtests is a group, counted and
+// listed only when it failed with output of its own rather than merely
+// because a subtest did.
+func gotestJSON(input ActionResult, params map[string]any) (ActionResult, error) {
+	maxOutput := getInt(params, "max_output", 20)
+	maxFailures := getInt(params, "max_failures", 20)
+
+	var (
+		passed, failed, skipped int
+		// output holds captured lines per testKey; testKey(pkg, "") is
+		// package-level output, and build output is keyed by import path.
+		output = make(map[string][]string)
+		// groups marks the tests that have subtests.
+		groups = make(map[string]bool)
+		// failedTests lists the failures to show, in the order they failed.
+		failedTests []string
+		// failedPkgs lists failing packages. explained marks those whose
+		// failure a failed test or build output already accounts for; the
+		// rest failed at package level (a panic outside a test, TestMain, a
+		// timeout), for the reason in "FAIL\tpkg [build failed]" if any.
+		failedPkgs  []string
+		explained   = make(map[string]bool)
+		reason      = make(map[string]string)
+		buildFailed []string
+		other       []string
+	)
+	for _, line := range input.Lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var ev testEvent
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &ev) != nil || ev.Action == "" {
+			other = append(other, line)
+			continue
+		}
+		pkg := ev.Package
+		if pkg == "" {
+			// Build events name the test binary too: "pkg [pkg.test]".
+			pkg, _, _ = strings.Cut(ev.ImportPath, " ")
+		}
+		key := testKey(pkg, ev.Test)
+		// Subtests start, and finish, before their parent does.
+		if i := strings.LastIndex(ev.Test, "/"); i >= 0 {
+			groups[testKey(pkg, ev.Test[:i])] = true
+		}
+
+		switch ev.Action {
+		case "output", "build-output":
+			line := strings.TrimRight(ev.Output, "\n")
+			if r, ok := strings.CutPrefix(line, "FAIL\t"+pkg+" "); ok && strings.HasPrefix(r, "[") {
+				reason[pkg] = r
+			} else if line != "" && !isTestNoise(line) {
+				output[key] = append(output[key], line)
+			}
+		case "build-fail":
+			explained[pkg] = true
+			buildFailed = append(buildFailed, pkg)
+		case "pass":
+			if ev.Test != "" && !groups[key] {
+				passed++
+			}
+		case "skip":
+			if ev.Test != "" && !groups[key] {
+				skipped++
+			}
+		case "fail":
+			if ev.Test == "" {
+				failedPkgs = append(failedPkgs, pkg)
+				continue
+			}
+			explained[pkg] = true
+			if !groups[key] || len(output[key]) > 0 {
+				failed++
+				failedTests = append(failedTests, key)
+			}
+		}
+	}
+
+	var out []string
+	for i, key := range failedTests {
+		if maxFailures > 0 && i >= maxFailures {
+			out = append(out, fmt.Sprintf("+%d more failures", len(failedTests)-maxFailures))
+			break
+		}
+		pkg, test, _ := strings.Cut(key, "\x00")
+		out = append(out, fmt.Sprintf("--- FAIL: %s (%s)", test, pkg))
+		out = appendCapped(out, output[key], maxOutput)
+	}
+	for _, path := range buildFailed {
+		out = append(out, fmt.Sprintf("FAIL %s [build failed]", path))
+		out = appendCapped(out, output[testKey(path, "")], maxOutput)
+	}
+	for _, pkg := range failedPkgs {
+		if explained[pkg] {
+			continue
+		}
+		header := "FAIL " + pkg
+		if r := reason[pkg]; r != "" {
+			header += " " + r
+		}
+		out = append(out, header)
+		out = appendCapped(out, output[testKey(pkg, "")], maxOutput)
+	}
+	for i, l := range other {
+		if maxOutput > 0 && i >= maxOutput {
+			out = append(out, fmt.Sprintf("+%d more lines", len(other)-maxOutput))
+			break
+		}
+		out = append(out, l)
+	}
+
+	summary := "No tests found"
+	if passed+failed+skipped > 0 {
+		summary = fmt.Sprintf("%d passed, %d failed", passed, failed)
+		if skipped > 0 {
+			summary += fmt.Sprintf(", %d skipped", skipped)
+		}
+	}
+	return ActionResult{Lines: append([]string{summary}, out...), Metadata: input.Metadata}, nil
+}
+
+func appendCapped(out, lines []string, max int) []string {
+	for i, l := range lines {
+		if max > 0 && i >= max {
+			return append(out, fmt.Sprintf("    +%d more lines", len(lines)-max))
+		}
+		// t.Log output is already indented by four spaces; keep any deeper
+		// indentation relative to that.
+		out = append(out, "    "+strings.TrimPrefix(strings.TrimRight(l, " \t"), "    "))
+	}
+	return out
+}
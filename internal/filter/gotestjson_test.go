@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGotestJSON(t *testing.T) {
+	input := lines(
+		`{"Action":"start","Package":"example.com/calc"}`,
+		`{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}`,
+		`{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0}`,
+		`{"Action":"run","Package":"example.com/calc","Test":"TestDiv"}`,
+		`{"Action":"run","Package":"example.com/calc","Test":"TestDiv/by_zero"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:21: want error, got 0\n"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"        extra detail\n"}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    --- FAIL: TestDiv/by_zero (0.00s)\n"}`,
+		`{"Action":"fail","Package":"example.com/calc","Test":"TestDiv/by_zero","Elapsed":0}`,
+		`{"Action":"output","Package":"example.com/calc","Test":"TestDiv","Output":"--- FAIL: TestDiv (0.00s)\n"}`,
+		`{"Action":"fail","Package":"example.com/calc","Test":"TestDiv","Elapsed":0}`,
+		`{"Action":"skip","Package":"example.com/calc","Test":"TestSlow","Elapsed":0}`,
+		`{"Action":"output","Package":"example.com/calc","Output":"FAIL\n"}`,
+		`{"Action":"output","Package":"example.com/calc","Output":"FAIL\texample.com/calc\t0.01s\n"}`,
+		`{"Action":"fail","Package":"example.com/calc","Elapsed":0.01}`,
+		`{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"# example.com/broken\n"}`,
+		`{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-output","Output":"./broken.go:3:1: syntax error: unexpected }\n"}`,
+		`{"ImportPath":"example.com/broken [example.com/broken.test]","Action":"build-fail"}`,
+		`{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [build failed]\n"}`,
+		`{"Action":"fail","Package":"example.com/broken","Elapsed":0}`,
+		`{"Action":"output","Package":"example.com/boom","Output":"panic: init failed\n"}`,
+		`{"Action":"output","Package":"example.com/boom","Output":"FAIL\texample.com/boom\t0.002s\n"}`,
+		`{"Action":"fail","Package":"example.com/boom","Elapsed":0}`,
+		"go: downloading example.com/dep v1.0.0",
+	)
+	res, err := gotestJSON(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"1 passed, 1 failed, 1 skipped",
+		"--- FAIL: TestDiv/by_zero (example.com/calc)",
+		"    calc_test.go:21: want error, got 0",
+		"        extra detail",
+		"FAIL example.com/broken [build failed]",
+		"    # example.com/broken",
+		"    ./broken.go:3:1: syntax error: unexpected }",
+		"FAIL example.com/boom",
+		"    panic: init failed",
+		"go: downloading example.com/dep v1.0.0",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGotestJSONCountsLeafTests(t *testing.T) {
+	input := lines(
+		`{"Action":"run","Package":"p","Test":"TestOK"}`,
+		`{"Action":"run","Package":"p","Test":"TestOK/a"}`,
+		`{"Action":"pass","Package":"p","Test":"TestOK/a"}`,
+		`{"Action":"run","Package":"p","Test":"TestOK/b"}`,
+		`{"Action":"pass","Package":"p","Test":"TestOK/b"}`,
+		`{"Action":"pass","Package":"p","Test":"TestOK"}`,
+		`{"Action":"run","Package":"p","Test":"TestMixed"}`,
+		`{"Action":"run","Package":"p","Test":"TestMixed/ok"}`,
+		`{"Action":"pass","Package":"p","Test":"TestMixed/ok"}`,
+		`{"Action":"run","Package":"p","Test":"TestMixed/bad"}`,
+		`{"Action":"fail","Package":"p","Test":"TestMixed/bad"}`,
+		`{"Action":"fail","Package":"p","Test":"TestMixed"}`,
+		`{"Action":"run","Package":"p","Test":"TestOwn"}`,
+		`{"Action":"run","Package":"p","Test":"TestOwn/bad"}`,
+		`{"Action":"fail","Package":"p","Test":"TestOwn/bad"}`,
+		`{"Action":"output","Package":"p","Test":"TestOwn","Output":"    own_test.go:9: setup broke\n"}`,
+		`{"Action":"fail","Package":"p","Test":"TestOwn"}`,
+	)
+	res, err := gotestJSON(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"3 passed, 3 failed",
+		"--- FAIL: TestMixed/bad (p)",
+		"--- FAIL: TestOwn/bad (p)",
+		"--- FAIL: TestOwn (p)",
+		"    own_test.go:9: setup broke",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGotestJSONOutputCap(t *testing.T) {
+	input := lines(
+		`{"Action":"output","Package":"p","Test":"TestX","Output":"    a\n"}`,
+		`{"Action":"output","Package":"p","Test":"TestX","Output":"    b\n"}`,
+		`{"Action":"output","Package":"p","Test":"TestX","Output":"    c\n"}`,
+		`{"Action":"fail","Package":"p","Test":"TestX"}`,
+	)
+	res, err := gotestJSON(input, map[string]any{"max_output": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0 passed, 1 failed", "--- FAIL: TestX (p)", "    a", "    b", "    +1 more lines"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestGotestJSONNoTests(t *testing.T) {
+	res, err := gotestJSON(lines(`{"Action":"output","Package":"p","Output":"?   \tp\t[no test files]\n"}`, `{"Action":"skip","Package":"p"}`), map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 1 || res.Lines[0] != "No tests found" {
+		t.Errorf("got %q", res.Lines)
+	}
+}
@@ -0,0 +1,851 @@
+package filter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// json_query implements a small, dependency-free subset of jq:
+//
+//	.a.b  .["k"]  .[0]  .[-1]  .[1:3]  .[]   paths, indexes, slices, iteration
+//	a | b                                     pipes
+//	select(cond)                              keep inputs where cond is truthy
+//	== != < <= > >=  and  or  not             comparisons and logic
+//	{name: .metadata.name, phase}             projection (ordered keys)
+//	[expr]  length  keys                      collection and builtins
+//	"str" 42 true false null                  literals
+//
+// Unlike jq, a projection value that yields several results is collected
+// into an array instead of multiplying the object.
+
+// jqExpr is a compiled query: it maps one input value to zero or more outputs.
+type jqExpr interface {
+	eval(v any) ([]any, error)
+}
+
+// jqObject is a projected object. It keeps the key order the query wrote,
+// which a Go map would lose.
+type jqObject struct {
+	keys []string
+	vals []any
+}
+
+type jqIdentity struct{}
+
+func (jqIdentity) eval(v any) ([]any, error) { return []any{v}, nil }
+
+type jqLiteral struct{ val any }
+
+func (l jqLiteral) eval(any) ([]any, error) { return []any{l.val}, nil }
+
+type jqPipe struct{ left, right jqExpr }
+
+func (p jqPipe) eval(v any) ([]any, error) {
+	in, err := p.left.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, x := range in {
+		res, err := p.right.eval(x)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, res...)
+	}
+	return out, nil
+}
+
+// jqStep is one segment of a path: a field, an index, a slice or [].
+type jqStep struct {
+	field    string
+	hasField bool
+	index    *int
+	from, to *int
+	iterate  bool
+}
+
+type jqPath struct{ steps []jqStep }
+
+func (p jqPath) eval(v any) ([]any, error) {
+	cur := []any{v}
+	for _, s := range p.steps {
+		var next []any
+		for _, x := range cur {
+			res, err := s.apply(x)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, res...)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+func (s jqStep) apply(v any) ([]any, error) {
+	if v == nil {
+		// Like jq, indexing null yields null and iterating it yields nothing.
+		if s.iterate {
+			return nil, nil
+		}
+		return []any{nil}, nil
+	}
+	switch {
+	case s.hasField:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot index %s with %q", jqType(v), s.field)
+		}
+		return []any{m[s.field]}, nil
+	case s.iterate:
+		switch c := v.(type) {
+		case []any:
+			return c, nil
+		case map[string]any:
+			keys := sortedKeys(c)
+			out := make([]any, len(keys))
+			for i, k := range keys {
+				out[i] = c[k]
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", jqType(v))
+	}
+
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot index %s with a number", jqType(v))
+	}
+	n := len(arr)
+	if s.index != nil {
+		i := *s.index
+		if i < 0 {
+			i += n
+		}
+		if i < 0 || i >= n {
+			return []any{nil}, nil
+		}
+		return []any{arr[i]}, nil
+	}
+	from, to := 0, n
+	if s.from != nil {
+		from = clampIndex(*s.from, n)
+	}
+	if s.to != nil {
+		to = clampIndex(*s.to, n)
+	}
+	if from > to {
+		from = to
+	}
+	return []any{arr[from:to:to]}, nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+type jqSelect struct{ cond jqExpr }
+
+func (s jqSelect) eval(v any) ([]any, error) {
+	res, err := s.cond.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res {
+		if truthy(r) {
+			return []any{v}, nil
+		}
+	}
+	return nil, nil
+}
+
+type jqBinary struct {
+	op          string
+	left, right jqExpr
+}
+
+func (b jqBinary) eval(v any) ([]any, error) {
+	l, err := first(b.left, v)
+	if err != nil {
+		return nil, err
+	}
+	// and/or short-circuit, as in jq.
+	switch b.op {
+	case "and":
+		if !truthy(l) {
+			return []any{false}, nil
+		}
+	case "or":
+		if truthy(l) {
+			return []any{true}, nil
+		}
+	}
+	r, err := first(b.right, v)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "and", "or":
+		return []any{truthy(r)}, nil
+	case "==":
+		return []any{jqEqual(l, r)}, nil
+	case "!=":
+		return []any{!jqEqual(l, r)}, nil
+	}
+	c := jqCompare(l, r)
+	switch b.op {
+	case "<":
+		return []any{c < 0}, nil
+	case "<=":
+		return []any{c <= 0}, nil
+	case ">":
+		return []any{c > 0}, nil
+	default: // ">="
+		return []any{c >= 0}, nil
+	}
+}
+
+type jqFunc struct{ name string }
+
+func (f jqFunc) eval(v any) ([]any, error) {
+	switch f.name {
+	case "not":
+		return []any{!truthy(v)}, nil
+	case "keys":
+		switch c := v.(type) {
+		case map[string]any:
+			keys := sortedKeys(c)
+			out := make([]any, len(keys))
+			for i, k := range keys {
+				out[i] = k
+			}
+			return []any{out}, nil
+		case []any:
+			out := make([]any, len(c))
+			for i := range c {
+				out[i] = float64(i)
+			}
+			return []any{out}, nil
+		}
+		return nil, fmt.Errorf("%s has no keys", jqType(v))
+	default: // "length"
+		switch c := v.(type) {
+		case nil:
+			return []any{float64(0)}, nil
+		case string:
+			return []any{float64(len([]rune(c)))}, nil
+		case []any:
+			return []any{float64(len(c))}, nil
+		case map[string]any:
+			return []any{float64(len(c))}, nil
+		case float64:
+			if c < 0 {
+				c = -c
+			}
+			return []any{c}, nil
+		}
+		return nil, fmt.Errorf("%s has no length", jqType(v))
+	}
+}
+
+type jqCollect struct{ inner jqExpr }
+
+func (c jqCollect) eval(v any) ([]any, error) {
+	res, err := c.inner.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = []any{}
+	}
+	return []any{res}, nil
+}
+
+type jqProject struct {
+	keys []string
+	vals []jqExpr
+}
+
+func (p jqProject) eval(v any) ([]any, error) {
+	obj := &jqObject{keys: p.keys, vals: make([]any, len(p.vals))}
+	for i, e := range p.vals {
+		res, err := e.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		switch len(res) {
+		case 0:
+			obj.vals[i] = nil
+		case 1:
+			obj.vals[i] = res[0]
+		default:
+			obj.vals[i] = res
+		}
+	}
+	return []any{obj}, nil
+}
+
+func first(e jqExpr, v any) (any, error) {
+	res, err := e.eval(v)
+	if err != nil || len(res) == 0 {
+		return nil, err
+	}
+	return res[0], nil
+}
+
+func truthy(v any) bool {
+	b, isBool := v.(bool)
+	return v != nil && (!isBool || b)
+}
+
+func jqEqual(a, b any) bool {
+	return jqCompare(a, b) == 0 && jqType(a) == jqType(b)
+}
+
+// jqTypeOrder ranks types the way jq sorts them.
+var jqTypeOrder = map[string]int{"null": 0, "boolean": 1, "number": 2, "string": 3, "array": 4, "object": 5}
+
+func jqCompare(a, b any) int {
+	ta, tb := jqType(a), jqType(b)
+	if ta != tb {
+		return jqTypeOrder[ta] - jqTypeOrder[tb]
+	}
+	switch x := a.(type) {
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		}
+		return 1
+	case float64:
+		y := b.(float64)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(x, b.(string))
+	case nil:
+		return 0
+	}
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return strings.Compare(jqEncode(a), jqEncode(b))
+}
+
+func jqType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jqEncode renders v as compact JSON. Integral numbers print without an
+// exponent, and projected objects keep their key order.
+func jqEncode(v any) string {
+	var b strings.Builder
+	writeJQ(&b, v)
+	return b.String()
+}
+
+func writeJQ(b *strings.Builder, v any) {
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case float64:
+		b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	case string:
+		q, _ := json.Marshal(x)
+		b.Write(q)
+	case []any:
+		b.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJQ(b, e)
+		}
+		b.WriteByte(']')
+	case map[string]any:
+		b.WriteByte('{')
+		for i, k := range sortedKeys(x) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJQ(b, k)
+			b.WriteByte(':')
+			writeJQ(b, x[k])
+		}
+		b.WriteByte('}')
+	case *jqObject:
+		b.WriteByte('{')
+		for i, k := range x.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJQ(b, k)
+			b.WriteByte(':')
+			writeJQ(b, x.vals[i])
+		}
+		b.WriteByte('}')
+	}
+}
+
+// jqParser is a recursive-descent parser over the query string.
+type jqParser struct {
+	src string
+	pos int
+}
+
+// parseJQ compiles a query, reporting the offset of the first syntax error.
+func parseJQ(src string) (jqExpr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &jqParser{src: src}
+	e, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return e, nil
+}
+
+func (p *jqParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("query %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *jqParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes tok if it comes next. Word tokens must not run on into an
+// identifier, so "or" does not match the start of "order".
+func (p *jqParser) accept(tok string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], tok) {
+		return false
+	}
+	end := p.pos + len(tok)
+	if isIdentByte(tok[len(tok)-1]) && end < len(p.src) && isIdentByte(p.src[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *jqParser) expect(tok string) error {
+	if !p.accept(tok) {
+		return p.errorf("expected %q", tok)
+	}
+	return nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *jqParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) {
+		p.pos++
+	}
+	if start < len(p.src) && p.src[start] >= '0' && p.src[start] <= '9' {
+		p.pos = start
+		return ""
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *jqParser) parsePipe() (jqExpr, error) {
+	left, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		left = jqPipe{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseOr() (jqExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseAnd() (jqExpr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = jqBinary{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parseCompare() (jqExpr, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	// Two-character operators first, so "<=" is not read as "<".
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return jqBinary{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *jqParser) parsePrimary() (jqExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of query")
+	}
+	switch c := p.src[p.pos]; {
+	case c == '.':
+		return p.parsePath()
+	case c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return jqLiteral{val: s}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("bad number")
+		}
+		return jqLiteral{val: n}, nil
+	case c == '(':
+		p.pos++
+		e, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case c == '[':
+		p.pos++
+		if p.accept("]") {
+			return jqLiteral{val: []any{}}, nil
+		}
+		e, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return jqCollect{inner: e}, p.expect("]")
+	case c == '{':
+		return p.parseProject()
+	}
+
+	word := p.ident()
+	switch word {
+	case "true":
+		return jqLiteral{val: true}, nil
+	case "false":
+		return jqLiteral{val: false}, nil
+	case "null":
+		return jqLiteral{val: nil}, nil
+	case "length", "keys", "not":
+		return jqFunc{name: word}, nil
+	case "select":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return jqSelect{cond: cond}, p.expect(")")
+	case "":
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return nil, p.errorf("unknown function %q", word)
+}
+
+// parsePath reads ".", ".a.b", ".[0]", ".a[].b", `.["key"]` and friends.
+func (p *jqParser) parsePath() (jqExpr, error) {
+	var steps []jqStep
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			break
+		}
+		switch p.src[p.pos] {
+		case '.':
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '"' {
+				s, err := p.parseString()
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, jqStep{field: s, hasField: true})
+				continue
+			}
+			if name := p.ident(); name != "" {
+				steps = append(steps, jqStep{field: name, hasField: true})
+			}
+			continue
+		case '[':
+			p.pos++
+			step, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			continue
+		}
+		break
+	}
+	if len(steps) == 0 {
+		return jqIdentity{}, nil
+	}
+	return jqPath{steps: steps}, nil
+}
+
+// parseBracket reads what follows "[": "]", `"key"]`, "n]" or "a:b]".
+func (p *jqParser) parseBracket() (jqStep, error) {
+	if p.accept("]") {
+		return jqStep{iterate: true}, nil
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		s, err := p.parseString()
+		if err != nil {
+			return jqStep{}, err
+		}
+		return jqStep{field: s, hasField: true}, p.expect("]")
+	}
+	var from *int
+	if !p.accept(":") {
+		n, err := p.parseNumber()
+		if err != nil {
+			return jqStep{}, err
+		}
+		if p.accept("]") {
+			return jqStep{index: &n}, nil
+		}
+		if err := p.expect(":"); err != nil {
+			return jqStep{}, err
+		}
+		from = &n
+	}
+	step := jqStep{from: from}
+	if p.accept("]") {
+		return step, nil
+	}
+	n, err := p.parseNumber()
+	if err != nil {
+		return jqStep{}, err
+	}
+	step.to = &n
+	return step, p.expect("]")
+}
+
+func (p *jqParser) parseProject() (jqExpr, error) {
+	p.pos++ // "{"
+	var keys []string
+	var vals []jqExpr
+	if p.accept("}") {
+		return jqProject{}, nil
+	}
+	for {
+		p.skipSpace()
+		var key string
+		if p.pos < len(p.src) && p.src[p.pos] == '"' {
+			s, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		} else if key = p.ident(); key == "" {
+			return nil, p.errorf("expected object key")
+		}
+		var val jqExpr = jqPath{steps: []jqStep{{field: key, hasField: true}}}
+		if p.accept(":") {
+			// Values stop at "," and "}", so a pipe needs parentheses, as in jq.
+			v, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			val = v
+		}
+		keys = append(keys, key)
+		vals = append(vals, val)
+		if p.accept("}") {
+			return jqProject{keys: keys, vals: vals}, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *jqParser) parseString() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			s, err := strconv.Unquote(p.src[start:p.pos])
+			if err != nil {
+				p.pos = start
+				return "", p.errorf("bad string literal")
+			}
+			return s, nil
+		}
+		p.pos++
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jqParser) parseNumber() (int, error) {
+	p.skipSpace()
+	start := p.pos
+	if p.pos < len(p.src) && p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("expected integer")
+	}
+	return n, nil
+}
+
+func validateJSONQuery(params map[string]any) error {
+	_, err := parseJQ(getStr(params, "query"))
+	return err
+}
+
+// jsonQuery runs a jq-style query over a JSON document, or over each value of
+// an NDJSON stream, and prints one compact line per result: strings raw,
+// everything else as compact JSON.
+func jsonQuery(input ActionResult, params map[string]any) (ActionResult, error) {
+	q, err := parseJQ(getStr(params, "query"))
+	if err != nil {
+		return input, fmt.Errorf("json_query: %w", err)
+	}
+	max := getInt(params, "max", 0)
+	overflowMsg := getStr(params, "overflow_msg")
+	if overflowMsg == "" {
+		overflowMsg = "+{n} more results"
+	}
+
+	dec := json.NewDecoder(strings.NewReader(strings.Join(input.Lines, "\n")))
+	var results []any
+	for {
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return input, fmt.Errorf("json_query: parse: %w", err)
+		}
+		res, err := q.eval(doc)
+		if err != nil {
+			return input, fmt.Errorf("json_query: %w", err)
+		}
+		results = append(results, res...)
+	}
+
+	out := make([]string, 0, len(results))
+	for i, r := range results {
+		if max > 0 && i >= max {
+			out = append(out, strings.ReplaceAll(overflowMsg, "{n}", strconv.Itoa(len(results)-max)))
+			break
+		}
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+			continue
+		}
+		out = append(out, jqEncode(r))
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
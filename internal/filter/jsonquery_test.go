@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+const podsJSON = `{"items": [
+  {"metadata": {"name": "api-1"}, "status": {"phase": "Running", "restarts": 0}},
+  {"metadata": {"name": "api-2"}, "status": {"phase": "CrashLoopBackOff", "restarts": 7}},
+  {"metadata": {"name": "worker"}, "status": {"phase": "Pending", "restarts": 1.5}}
+]}`
+
+func TestJSONQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{".items[].metadata.name", []string{"api-1", "api-2", "worker"}},
+		{".items[1:][].metadata.name", []string{"api-2", "worker"}},
+		{".items[-1].status.phase", []string{"Pending"}},
+		{".items | length", []string{"3"}},
+		{`.items[] | select(.status.phase != "Running" and .status.restarts > 1) | .metadata.name`, []string{"api-2", "worker"}},
+		{`.items[] | select(.status.restarts >= 7 or .metadata.name == "api-1") | {name: .metadata.name, phase: .status.phase}`,
+			[]string{`{"name":"api-1","phase":"Running"}`, `{"name":"api-2","phase":"CrashLoopBackOff"}`}},
+		{".items[0].status | {phase, restarts}", []string{`{"phase":"Running","restarts":0}`}},
+		{"[.items[].metadata.name]", []string{`["api-1","api-2","worker"]`}},
+		{".items[0].status | keys", []string{`["phase","restarts"]`}},
+		{`.items[] | select(.status.phase == "Running" | not) | .metadata["name"]`, []string{"api-2", "worker"}},
+		{".missing.deeper", []string{"null"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res, err := jsonQuery(lines(strings.Split(podsJSON, "\n")...), map[string]any{"query": tt.query})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(res.Lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", res.Lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONQueryNDJSONAndMax(t *testing.T) {
+	input := lines(`{"level":"info","msg":"a"}`, `{"level":"error","msg":"b"}`, `{"level":"error","msg":"c"}`, `{"level":"error","msg":"d"}`)
+	res, err := jsonQuery(input, map[string]any{"query": `select(.level == "error") | .msg`, "max": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b", "c", "+1 more results"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+
+	res, err = jsonQuery(input, map[string]any{"query": ".msg", "max": 1, "overflow_msg": "... {n} more (100%)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Lines[len(res.Lines)-1]; got != "... 3 more (100%)" {
+		t.Errorf("custom overflow msg: %q", got)
+	}
+}
+
+func TestJSONQueryRuntimeError(t *testing.T) {
+	if _, err := jsonQuery(lines(`{"a": "x"}`), map[string]any{"query": ".a[]"}); err == nil {
+		t.Error("expected error iterating over a string")
+	}
+}
+
+func TestJSONQueryValidation(t *testing.T) {
+	for _, q := range []string{"", ".a[", `select(.a == )`, ".a | frobnicate", "{name: }", `.["unterminated]`} {
+		yaml := "name: \"bad\"\nmatch:\n  command: \"x\"\npipeline:\n  - action: \"json_query\"\n    query: '" + q + "'\n"
+		if _, err := ParseFilter([]byte(yaml)); err == nil {
+			t.Errorf("query %q: expected ParseFilter to reject it", q)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package filter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// ReportsKey is the metadata key under which the engine passes the contents
+// of test report files collected after the run (see Inject.Reports).
+const ReportsKey = "reports"
+
+// junitLocationRe picks the "first relevant line" of a failure body: the
+// first frame or diagnostic that points at a file and line.
+var junitLocationRe = utils.NewLazyRegex(`[\w./\\-]+\.\w+:(line )?\d+|\(\S+:\d+\)`)
+
+// Frames of test frameworks, assertion libraries and runtimes, which sit
+// above the test's own frame in a trace but never say where it failed:
+// packages prefix a JVM "at ..." frame, paths appear anywhere in the line.
+var (
+	junitFrameworkPackages = []string{"org.junit.", "org.opentest4j.", "java.", "jdk.", "sun."}
+	junitFrameworkPaths    = []string{"_pytest/", "node_modules/"}
+)
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+// trxResult is a Visual Studio TRX <UnitTestResult>, what
+// `dotnet test --logger trx` writes.
+type trxResult struct {
+	TestName string `xml:"testName,attr"`
+	Outcome  string `xml:"outcome,attr"`
+	Output   struct {
+		ErrorInfo struct {
+			Message    string `xml:"Message"`
+			StackTrace string `xml:"StackTrace"`
+		} `xml:"ErrorInfo"`
+	} `xml:"Output"`
+}
+
+type junitTotals struct {
+	passed, failed, skipped int
+}
+
+// failureLines renders one failed test: "FAIL name: message", then its first
+// relevant line, indented, if the body has one.
+func failureLines(name, message, body string) []string {
+	message = firstLine(message)
+	if message == "" {
+		message = firstLine(body)
+	}
+	entry := "FAIL " + name
+	if message != "" {
+		entry += ": " + message
+	}
+	if loc := relevantLine(body, message); loc != "" {
+		return []string{entry, "  " + loc}
+	}
+	return []string{entry}
+}
+
+// junit reduces JUnit XML (Maven surefire, Gradle, pytest --junitxml,
+// jest-junit) or TRX reports to a "N passed, M failed, K skipped" line plus
+// each failure's test name, message and first relevant line. Reports come
+// from the files the engine collected after the run, or else from the XML on
+// the input itself. No test cases at all is an error, so the engine falls
+// back to raw output (a build failure leaves no report to summarise).
+func junit(input ActionResult, params map[string]any) (ActionResult, error) {
+	maxFailures := getInt(params, "max_failures", 20)
+
+	docs, _ := input.Metadata[ReportsKey].([]string)
+	if len(docs) == 0 {
+		docs = []string{strings.Join(input.Lines, "\n")}
+	}
+
+	var totals junitTotals
+	var failures []string
+	for _, doc := range docs {
+		docFailures, err := parseTestReport(doc, &totals)
+		if err != nil {
+			return input, fmt.Errorf("junit: %w", err)
+		}
+		failures = append(failures, docFailures...)
+	}
+	if totals.passed+totals.failed+totals.skipped == 0 {
+		return input, fmt.Errorf("junit: no test cases found")
+	}
+
+	out := []string{fmt.Sprintf("%d passed, %d failed, %d skipped", totals.passed, totals.failed, totals.skipped)}
+	shown := 0
+	for _, line := range failures {
+		if strings.HasPrefix(line, "FAIL ") {
+			if maxFailures > 0 && shown >= maxFailures {
+				out = append(out, fmt.Sprintf("+%d more failures", totals.failed-shown))
+				break
+			}
+			shown++
+		}
+		out = append(out, line)
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
+
+// parseTestReport walks one XML document and tallies every <testcase> (JUnit)
+// and <UnitTestResult> (TRX) it contains, at any nesting depth. It returns
+// the failureLines of the failed tests.
+func parseTestReport(doc string, totals *junitTotals) ([]string, error) {
+	start := strings.IndexByte(doc, '<')
+	if start < 0 {
+		return nil, nil
+	}
+	var failures []string
+	dec := xml.NewDecoder(strings.NewReader(doc[start:]))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return failures, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse report: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "testcase":
+			var tc junitCase
+			if err := dec.DecodeElement(&tc, &se); err != nil {
+				return nil, fmt.Errorf("parse testcase: %w", err)
+			}
+			name := tc.Name
+			if tc.Classname != "" {
+				name = tc.Classname + "." + tc.Name
+			}
+			switch {
+			case tc.Failure != nil:
+				totals.failed++
+				failures = append(failures, failureLines(name, tc.Failure.Message, tc.Failure.Body)...)
+			case tc.Error != nil:
+				totals.failed++
+				failures = append(failures, failureLines(name, tc.Error.Message, tc.Error.Body)...)
+			case tc.Skipped != nil:
+				totals.skipped++
+			default:
+				totals.passed++
+			}
+		case "UnitTestResult":
+			var r trxResult
+			if err := dec.DecodeElement(&r, &se); err != nil {
+				return nil, fmt.Errorf("parse UnitTestResult: %w", err)
+			}
+			switch r.Outcome {
+			case "Passed":
+				totals.passed++
+			case "Failed", "Error", "Timeout", "Aborted":
+				totals.failed++
+				failures = append(failures, failureLines(r.TestName, r.Output.ErrorInfo.Message, r.Output.ErrorInfo.StackTrace)...)
+			default: // NotExecuted, Inconclusive, ...
+				totals.skipped++
+			}
+		}
+	}
+}
+
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// relevantLine returns the first line of a failure body that points at a file
+// and line, skipping anything that merely repeats the message and the frames
+// of test frameworks and runtimes. A trace with nothing but such frames
+// yields its first one.
+func relevantLine(body, message string) string {
+	fallback := ""
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line == message || !junitLocationRe.Re().MatchString(line) {
+			continue
+		}
+		if !isFrameworkFrame(line) {
+			return line
+		}
+		if fallback == "" {
+			fallback = line
+		}
+	}
+	return fallback
+}
+
+func isFrameworkFrame(line string) bool {
+	frame := strings.TrimPrefix(line, "at ")
+	for _, pkg := range junitFrameworkPackages {
+		if strings.HasPrefix(frame, pkg) {
+			return true
+		}
+	}
+	for _, path := range junitFrameworkPaths {
+		if strings.Contains(line, path) {
+			return true
+		}
+	}
+	return false
+}
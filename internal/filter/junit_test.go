@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+const surefireReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="com.example.CalcTest" tests="4" failures="1" errors="1" skipped="1">
+  <testcase classname="com.example.CalcTest" name="adds" time="0.01"/>
+  <testcase classname="com.example.CalcTest" name="divides" time="0.02">
+    <failure message="expected:&lt;2&gt; but was:&lt;3&gt;" type="org.opentest4j.AssertionFailedError">org.opentest4j.AssertionFailedError: expected:&lt;2&gt; but was:&lt;3&gt;
+	at org.junit.jupiter.api.AssertionUtils.fail(AssertionUtils.java:55)
+	at com.example.CalcTest.divides(CalcTest.java:21)
+</failure>
+  </testcase>
+  <testcase classname="com.example.CalcTest" name="overflows">
+    <error message="boom" type="java.lang.IllegalStateException"/>
+  </testcase>
+  <testcase classname="com.example.CalcTest" name="later"><skipped/></testcase>
+</testsuite>`
+
+func TestJunitFromReports(t *testing.T) {
+	pytest := `<testsuites><testsuite name="pytest"><testcase classname="tests.test_api" name="test_ok"/></testsuite></testsuites>`
+	input := ActionResult{
+		Lines:    []string{"[INFO] BUILD FAILURE"},
+		Metadata: map[string]any{ReportsKey: []string{surefireReport, pytest}},
+	}
+	res, err := junit(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"2 passed, 2 failed, 1 skipped",
+		"FAIL com.example.CalcTest.divides: expected:<2> but was:<3>",
+		"  at com.example.CalcTest.divides(CalcTest.java:21)",
+		"FAIL com.example.CalcTest.overflows: boom",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestJunitFromStdoutTRX(t *testing.T) {
+	input := lines(
+		"Results File: /tmp/run.trx",
+		`<TestRun><Results>`,
+		`<UnitTestResult testName="Calc.Adds" outcome="Passed"/>`,
+		`<UnitTestResult testName="Calc.Divides" outcome="Failed"><Output><ErrorInfo><Message>Assert.Equal() Failure</Message><StackTrace>   at Calc.Divides() in /src/CalcTests.cs:line 14</StackTrace></ErrorInfo></Output></UnitTestResult>`,
+		`<UnitTestResult testName="Calc.Later" outcome="NotExecuted"/>`,
+		`</Results></TestRun>`,
+	)
+	res, err := junit(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"1 passed, 1 failed, 1 skipped",
+		"FAIL Calc.Divides: Assert.Equal() Failure",
+		"  at Calc.Divides() in /src/CalcTests.cs:line 14",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestJunitMaxFailures(t *testing.T) {
+	input := lines(`<testsuite>`,
+		`<testcase name="a"><failure message="x"/></testcase>`,
+		`<testcase name="b"><failure message="y"/></testcase>`,
+		`<testcase name="c"><failure message="z"/></testcase>`,
+		`</testsuite>`)
+	res, err := junit(input, map[string]any{"max_failures": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0 passed, 3 failed, 0 skipped", "FAIL a: x", "+2 more failures"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestRelevantLineSkipsFrameworkFrames(t *testing.T) {
+	tests := []struct{ body, want string }{
+		{"/venv/lib/python3.12/site-packages/_pytest/python.py:195: in pytest_pyfunc_call\ntests/test_api.py:12: AssertionError", "tests/test_api.py:12: AssertionError"},
+		{"Error: expect(received)\n    at Object.toBe (node_modules/expect/build/index.js:218:22)\n    at Object.<anonymous> (src/sum.test.js:4:13)", "at Object.<anonymous> (src/sum.test.js:4:13)"},
+		{"at java.base/java.lang.Thread.run(Thread.java:833)", "at java.base/java.lang.Thread.run(Thread.java:833)"},
+	}
+	for _, tt := range tests {
+		if got := relevantLine(tt.body, ""); got != tt.want {
+			t.Errorf("relevantLine(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestJunitNoTestCases(t *testing.T) {
+	if _, err := junit(lines("error: cannot find symbol"), map[string]any{}); err == nil {
+		t.Error("expected error when the input holds no test report")
+	}
+}
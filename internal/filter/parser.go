@@ -44,6 +44,42 @@ func ValidateFilter(f *Filter) error {
 		if _, ok := GetAction(action.ActionName); !ok {
 			return fmt.Errorf("validate filter %q: pipeline[%d] unknown action %q", f.Name, i, action.ActionName)
 		}
+		if err := validateCaptureAs(action.Params); err != nil {
+			return fmt.Errorf("validate filter %q: pipeline[%d] %s: %w", f.Name, i, action.ActionName, err)
+		}
+		if validate, ok := validators[action.ActionName]; ok {
+			if err := validate(action.Params); err != nil {
+				return fmt.Errorf("validate filter %q: pipeline[%d] %s: %w", f.Name, i, action.ActionName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validators check action params at load time for actions whose params are
+// too structured to fail cleanly at run time. A filter that fails here is
+// rejected by ParseFilter instead of degrading to raw output on every run.
+var validators = map[string]func(params map[string]any) error{
+	"json_query": validateJSONQuery,
+}
+
+// validatePipeline checks the action names and params of a nested pipeline.
+func validatePipeline(p Pipeline) error {
+	for i, action := range p {
+		if action.ActionName == "" {
+			return fmt.Errorf("pipeline[%d] missing 'action'", i)
+		}
+		if _, ok := GetAction(action.ActionName); !ok {
+			return fmt.Errorf("pipeline[%d] unknown action %q", i, action.ActionName)
+		}
+		if err := validateCaptureAs(action.Params); err != nil {
+			return fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
+		}
+		if validate, ok := validators[action.ActionName]; ok {
+			if err := validate(action.Params); err != nil {
+				return fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,179 @@
+package filter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pathNode is a directory in the tree path_tree rebuilds.
+type pathNode struct {
+	dirs  map[string]*pathNode
+	files map[string]bool
+}
+
+func newPathNode() *pathNode {
+	return &pathNode{dirs: make(map[string]*pathNode), files: make(map[string]bool)}
+}
+
+// dir returns the child directory name, creating it if needed. A name first
+// seen as a file (find and ls -R list directories as entries too) becomes a
+// directory once something is found inside it.
+func (n *pathNode) dir(name string) *pathNode {
+	d, ok := n.dirs[name]
+	if !ok {
+		d = newPathNode()
+		n.dirs[name] = d
+		delete(n.files, name)
+	}
+	return d
+}
+
+func (n *pathNode) add(path string) {
+	isDir := strings.HasSuffix(path, "/")
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	node := n
+	for i, part := range parts {
+		last := i == len(parts)-1
+		// Skip "." and the empty segments of "a//b", but keep the leading empty
+		// segment of an absolute path so it renders as "/".
+		if part == "." || (part == "" && i > 0) {
+			continue
+		}
+		if !last || isDir {
+			node = node.dir(part)
+			continue
+		}
+		if _, ok := node.dirs[part]; !ok {
+			node.files[part] = true
+		}
+	}
+}
+
+// countFiles tallies the files under n, recursively, by extension.
+func (n *pathNode) countFiles(byExt map[string]int) int {
+	total := len(n.files)
+	for name := range n.files {
+		byExt[filepath.Ext(name)]++
+	}
+	for _, d := range n.dirs {
+		total += d.countFiles(byExt)
+	}
+	return total
+}
+
+// path_tree rebuilds the directory hierarchy from a list of paths (find, git
+// ls-files, rg --files, ls -R) and prints it as an indented tree. Directories
+// are printed with their full path and files by name beneath them, so every
+// entry still resolves, unlike compact_path. Chains of directories holding a
+// single directory are merged, and a directory with more than max_children
+// entries collapses to "dir/ (N files: 80 .go, 12 .md)".
+func pathTree(input ActionResult, params map[string]any) (ActionResult, error) {
+	maxChildren := getInt(params, "max_children", 20)
+	if maxChildren < 0 {
+		return input, fmt.Errorf("path_tree: 'max_children' must not be negative")
+	}
+	indent := getStr(params, "indent")
+	if indent == "" {
+		indent = "  "
+	}
+
+	root := newPathNode()
+	prefix := ""
+	for _, line := range input.Lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// ls -R prints "dir:" before the names it holds.
+		if strings.HasSuffix(line, ":") {
+			prefix = strings.TrimSuffix(line, ":") + "/"
+			root.add(prefix)
+			continue
+		}
+		if prefix != "" && !strings.Contains(line, "/") {
+			line = prefix + line
+		}
+		root.add(strings.TrimPrefix(line, "./"))
+	}
+
+	var out []string
+	renderPathTree(root, "", 0, maxChildren, indent, &out)
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
+
+func renderPathTree(n *pathNode, prefix string, depth, maxChildren int, indent string, out *[]string) {
+	pad := strings.Repeat(indent, depth)
+
+	names := make([]string, 0, len(n.dirs))
+	for name := range n.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := n.dirs[name]
+		full := prefix + name + "/"
+		for len(d.files) == 0 && len(d.dirs) == 1 {
+			for child, cd := range d.dirs {
+				full += child + "/"
+				d = cd
+			}
+		}
+		if maxChildren > 0 && len(d.files)+len(d.dirs) > maxChildren {
+			*out = append(*out, pad+full+" ("+fileSummary(d)+")")
+			continue
+		}
+		*out = append(*out, pad+full)
+		renderPathTree(d, full, depth+1, maxChildren, indent, out)
+	}
+
+	files := make([]string, 0, len(n.files))
+	for name := range n.files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		// Top-level files carry no directory line above them, so the name is
+		// the full path.
+		*out = append(*out, pad+name)
+	}
+}
+
+// fileSummary describes a collapsed directory: "92 files: 80 .go, 12 .md",
+// listing the three commonest extensions and lumping the rest as "other".
+func fileSummary(n *pathNode) string {
+	byExt := make(map[string]int)
+	total := n.countFiles(byExt)
+	exts := make([]string, 0, len(byExt))
+	for ext := range byExt {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if byExt[exts[i]] != byExt[exts[j]] {
+			return byExt[exts[i]] > byExt[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+
+	parts := make([]string, 0, 4)
+	other := 0
+	for i, ext := range exts {
+		if i >= 3 || ext == "" {
+			other += byExt[ext]
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", byExt[ext], ext))
+	}
+	if other > 0 {
+		parts = append(parts, fmt.Sprintf("%d other", other))
+	}
+	noun := "files"
+	if total == 1 {
+		noun = "file"
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("%d %s", total, noun)
+	}
+	return fmt.Sprintf("%d %s: %s", total, noun, strings.Join(parts, ", "))
+}
@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPathTree(t *testing.T) {
+	input := lines(
+		"./README.md",
+		"./cmd/snip/main.go",
+		"./internal/filter/actions.go",
+		"./internal/filter/actions_test.go",
+		"./internal/filter/testdata/",
+		"./internal/engine/pipeline.go",
+		"./go.mod",
+	)
+	res, err := pathTree(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"cmd/snip/",
+		"  main.go",
+		"internal/",
+		"  internal/engine/",
+		"    pipeline.go",
+		"  internal/filter/",
+		"    internal/filter/testdata/",
+		"    actions.go",
+		"    actions_test.go",
+		"README.md",
+		"go.mod",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPathTreeCollapsesLargeDirs(t *testing.T) {
+	var in []string
+	for i := 0; i < 8; i++ {
+		in = append(in, fmt.Sprintf("src/pkg/file%d.go", i))
+	}
+	in = append(in, "src/pkg/doc.md", "src/pkg/sub/x.go", "src/pkg/Makefile", "src/main.go")
+	res, err := pathTree(lines(in...), map[string]any{"max_children": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"src/",
+		"  src/pkg/ (11 files: 9 .go, 1 .md, 1 other)",
+		"  main.go",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestPathTreeLsRecursive(t *testing.T) {
+	input := lines(
+		".:",
+		"docs",
+		"main.go",
+		"",
+		"./docs:",
+		"guide.md",
+	)
+	res, err := pathTree(input, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"docs/", "  guide.md", "main.go"}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+var (
+	// progressMarkRe finds what makes a line look like progress: a run of bar
+	// glyphs (wget's dots, "=====>", block characters), a percentage, or the
+	// elapsed and remaining times of curl's meter.
+	progressMarkRe = utils.NewLazyRegex(`[=#.>━─█▉▊▋▌▍▎▏░▒▓■]{3,}|\d{1,3}(?:\.\d+)?%|--:--:--|\d+:\d\d:\d\d`)
+	// progressNoiseRe matches everything else a bar line is made of: sizes,
+	// rates, counts, elapsed and remaining times, and "eta".
+	progressNoiseRe = utils.NewLazyRegex(`(?i)\d+(?:\.\d+)?\s*(?:[kmgt]i?b?(?:/s)?|b(?:/s)?|it/s|s/it|ms|s)?\b|--:--:--|\beta\b|[=#.>━─█▉▊▋▌▍▎▏░▒▓■]`)
+)
+
+// termScreen replays terminal output: enough cursor movement and erasing to
+// know what a progress display left on screen once it finished.
+type termScreen struct {
+	rows               [][]rune
+	row, col           int
+	savedRow, savedCol int
+}
+
+func (s *termScreen) put(r rune) {
+	line := s.rows[s.row]
+	for len(line) < s.col {
+		line = append(line, ' ')
+	}
+	if s.col < len(line) {
+		line[s.col] = r
+	} else {
+		line = append(line, r)
+	}
+	s.rows[s.row] = line
+	s.col++
+}
+
+// moveRow moves the cursor to row, adding rows below the last as needed.
+func (s *termScreen) moveRow(row int) {
+	s.row = max(row, 0)
+	if n := s.row + 1 - len(s.rows); n > 0 {
+		s.rows = append(s.rows, make([][]rune, n)...)
+	}
+}
+
+// write replays one line of captured output, which ends in a newline.
+func (s *termScreen) write(line string) {
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; r {
+		case '\r':
+			s.col = 0
+		case '\b':
+			s.col = max(s.col-1, 0)
+		case '\x1b':
+			i = s.escape(rs, i)
+		default:
+			if r >= ' ' || r == '\t' {
+				s.put(r)
+			}
+		}
+	}
+	s.moveRow(s.row + 1)
+	s.col = 0
+}
+
+// escape applies the escape sequence starting at rs[i] and returns the index
+// of its last rune. Sequences that do not move the cursor or erase text,
+// colours included, are dropped.
+func (s *termScreen) escape(rs []rune, i int) int {
+	if i+1 >= len(rs) {
+		return i
+	}
+	switch rs[i+1] {
+	case '[':
+	case ']':
+		// OSC (window titles, hyperlinks) runs to BEL or ESC \.
+		for j := i + 2; j < len(rs); j++ {
+			if rs[j] == '\a' {
+				return j
+			}
+			if rs[j] == '\x1b' && j+1 < len(rs) && rs[j+1] == '\\' {
+				return j + 1
+			}
+		}
+		return len(rs) - 1
+	case '7':
+		s.savedRow, s.savedCol = s.row, s.col
+		return i + 1
+	case '8':
+		s.moveRow(s.savedRow)
+		s.col = s.savedCol
+		return i + 1
+	default:
+		return i + 1
+	}
+
+	j := i + 2
+	for j < len(rs) && (rs[j] < 0x40 || rs[j] > 0x7e) {
+		j++
+	}
+	if j >= len(rs) {
+		return len(rs) - 1
+	}
+	arg := strings.TrimLeft(string(rs[i+2:j]), "?")
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		n = 0
+	}
+	count := max(n, 1)
+	switch rs[j] {
+	case 'A':
+		s.moveRow(s.row - count)
+	case 'B':
+		s.moveRow(s.row + count)
+	case 'C':
+		s.col += count
+	case 'D':
+		s.col = max(s.col-count, 0)
+	case 'G':
+		s.col = count - 1
+	case 'K':
+		s.eraseLine(n)
+	case 'J':
+		if n == 0 {
+			s.eraseLine(0)
+			s.rows = s.rows[:s.row+1]
+		}
+	case 's':
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u':
+		s.moveRow(s.savedRow)
+		s.col = s.savedCol
+	}
+	return j
+}
+
+// eraseLine implements "ESC [ n K": 0 erases to the end of the line, 1 to
+// the cursor, 2 the whole line.
+func (s *termScreen) eraseLine(mode int) {
+	line := s.rows[s.row]
+	switch mode {
+	case 0:
+		if s.col < len(line) {
+			s.rows[s.row] = line[:s.col]
+		}
+	case 1:
+		for k := 0; k <= s.col && k < len(line); k++ {
+			line[k] = ' '
+		}
+	case 2:
+		s.rows[s.row] = nil
+	}
+}
+
+// isProgressBar reports whether line is nothing but a progress display: a bar
+// or percentage plus sizes, rates and times, with no words of its own.
+func isProgressBar(line string) bool {
+	if !progressMarkRe.Re().MatchString(line) {
+		return false
+	}
+	rest := progressNoiseRe.Re().ReplaceAllString(line, "")
+	return !strings.ContainsFunc(rest, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	})
+}
+
+// collapseProgress replays carriage returns, backspaces, erase-line and
+// cursor-movement sequences the way a terminal would, so a progress display
+// redrawn thousands of times on one line (or over several lines, moving the
+// cursor up) leaves only its final state. Other escape sequences, colours
+// included, are removed. Lines that are then nothing but a progress bar are
+// dropped unless keep_bars is set.
+func collapseProgress(input ActionResult, params map[string]any) (ActionResult, error) {
+	keepBars := getBool(params, "keep_bars")
+
+	s := &termScreen{rows: make([][]rune, 1, len(input.Lines)+1)}
+	for _, line := range input.Lines {
+		s.write(line)
+	}
+	// Every line ends by moving to a fresh row; drop the one after the last.
+	rows := s.rows[:len(s.rows)-1]
+	if len(s.rows[len(s.rows)-1]) > 0 {
+		rows = s.rows
+	}
+
+	out := make([]string, 0, len(rows))
+	for _, r := range rows {
+		line := strings.TrimRight(string(r), " ")
+		if !keepBars && isProgressBar(line) {
+			continue
+		}
+		out = append(out, line)
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
@@ -0,0 +1,86 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollapseProgressCarriageReturn(t *testing.T) {
+	input := lines(
+		"Downloading file.tar.gz",
+		"  0% [>    ]\r 50% [==>  ]\r100% [=====]",
+		"Fetching index\r\x1b[KFetched index (12 kB)",
+		"done",
+	)
+	res, err := collapseProgress(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Downloading file.tar.gz", "Fetched index (12 kB)", "done"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCollapseProgressOverwriteKeepsTail(t *testing.T) {
+	// Without an erase, a shorter redraw leaves the end of the longer one.
+	res, err := collapseProgress(lines("abcdef\rXY", "a\bb", "\x1b[31mred\x1b[0m"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"XYcdef", "b", "red"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCollapseProgressCursorUp(t *testing.T) {
+	// BuildKit-style redraw: move up over the previous block and rewrite it.
+	input := lines(
+		"[+] Building 0.1s (1/2)",
+		" => [1/2] FROM alpine 0.1s",
+		"\x1b[2A\x1b[K[+] Building 1.2s (2/2) FINISHED",
+		"\x1b[K => [1/2] FROM alpine 0.2s",
+		"\x1b[K => [2/2] RUN true 1.0s",
+	)
+	res, err := collapseProgress(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[+] Building 1.2s (2/2) FINISHED",
+		" => [1/2] FROM alpine 0.2s",
+		" => [2/2] RUN true 1.0s",
+	}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCollapseProgressDropsBars(t *testing.T) {
+	input := lines(
+		"  % Total    % Received % Xferd  Average Speed",
+		"100  1234k  100  1234k    0     0  1234k      0  0:00:01 --:--:--  0:00:01 1234k",
+		"     0K .......... .......... 50% 1.2M 1s",
+		" 45%|████▌     | 45/100 [00:02<00:03, 1.2it/s]",
+		"   ━━━━━━━━━━━━━━━━━━━━ 10.2/10.2 MB 5.1 MB/s eta 0:00:00",
+		"50% of tests passed",
+		"",
+	)
+	want := []string{"  % Total    % Received % Xferd  Average Speed", "50% of tests passed", ""}
+	res, err := collapseProgress(input, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+
+	res, err = collapseProgress(input, map[string]any{"keep_bars": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != len(input.Lines) {
+		t.Errorf("keep_bars: got %d lines, want %d", len(res.Lines), len(input.Lines))
+	}
+}
@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// captureKey returns the sort or count key re finds in line: the group named
+// "key" if the pattern has one, else the first capture group, else the whole
+// match. ok is false when the line does not match.
+func captureKey(re *regexp.Regexp, line string) (string, bool) {
+	m := re.FindStringSubmatch(line)
+	if m == nil {
+		return "", false
+	}
+	if i := re.SubexpIndex("key"); i > 0 {
+		return m[i], true
+	}
+	if len(m) > 1 {
+		return m[1], true
+	}
+	return m[0], true
+}
+
+// countBy counts matching lines per captured key and prints the keys as a
+// ranked table, most frequent first, ties in first-seen order. Only the 'top'
+// keys are listed; the rest are summed up by 'overflow_msg'. Unlike group_by,
+// the default rendering right-aligns the counts so the table stays readable.
+// Metadata "counts" maps every key to its count.
+func countBy(input ActionResult, params map[string]any) (ActionResult, error) {
+	re, err := compilePattern(params, "pattern")
+	if err != nil {
+		return input, fmt.Errorf("count_by: %w", err)
+	}
+	top := getInt(params, "top", 10)
+	overflowMsg := getStr(params, "overflow_msg")
+	if overflowMsg == "" {
+		overflowMsg = "and {n} more"
+	}
+	var tmpl *template.Template
+	if s := getStr(params, "format"); s != "" {
+		if tmpl, err = template.New("count_by").Parse(s); err != nil {
+			return input, fmt.Errorf("count_by: format template: %w", err)
+		}
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for _, line := range input.Lines {
+		key, ok := captureKey(re, line)
+		if !ok {
+			continue
+		}
+		if _, seen := counts[key]; !seen {
+			order = append(order, key)
+		}
+		counts[key]++
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+
+	shown := order
+	if top > 0 && len(order) > top {
+		shown = order[:top]
+	}
+	width := 0
+	if len(shown) > 0 {
+		width = len(strconv.Itoa(counts[shown[0]]))
+	}
+
+	out := make([]string, 0, len(shown)+1)
+	for _, key := range shown {
+		if tmpl == nil {
+			out = append(out, fmt.Sprintf("%*d  %s", width, counts[key], key))
+			continue
+		}
+		var buf strings.Builder
+		if err := tmpl.Execute(&buf, map[string]any{"Key": key, "Count": counts[key]}); err != nil {
+			return input, fmt.Errorf("count_by template: %w", err)
+		}
+		out = append(out, buf.String())
+	}
+	if rest := len(order) - len(shown); rest > 0 {
+		out = append(out, strings.ReplaceAll(overflowMsg, "{n}", strconv.Itoa(rest)))
+	}
+
+	meta := copyMeta(input.Metadata)
+	meta["counts"] = counts
+
+	if getBool(params, "append") {
+		out = append(append([]string{}, input.Lines...), out...)
+	}
+	return ActionResult{Lines: out, Metadata: meta}, nil
+}
+
+// numberRe finds the first number in a sort key.
+var numberRe = utils.NewLazyRegex(`-?\d+(?:\.\d+)?`)
+
+// sortLines sorts lines lexically or, with by: numeric, by the first number
+// in each line. With 'pattern' the key is the captured text instead of the
+// whole line; lines without a key keep their relative order after the sorted
+// ones. The sort is stable, 'reverse' flips it and 'unique' drops repeated
+// lines.
+func sortLines(input ActionResult, params map[string]any) (ActionResult, error) {
+	by := getStr(params, "by")
+	if by == "" {
+		by = "lexical"
+	}
+	if by != "lexical" && by != "numeric" {
+		return input, fmt.Errorf("sort: 'by' must be lexical or numeric, got %q", by)
+	}
+	var re *regexp.Regexp
+	if getStr(params, "pattern") != "" {
+		var err error
+		if re, err = compilePattern(params, "pattern"); err != nil {
+			return input, fmt.Errorf("sort: %w", err)
+		}
+	}
+	reverse := getBool(params, "reverse")
+
+	type keyed struct {
+		line string
+		key  string
+		num  float64
+	}
+	var sorted []keyed
+	var rest []string
+	for _, line := range input.Lines {
+		k := keyed{line: line, key: line}
+		if re != nil {
+			key, ok := captureKey(re, line)
+			if !ok {
+				rest = append(rest, line)
+				continue
+			}
+			k.key = key
+		}
+		if by == "numeric" {
+			n, err := strconv.ParseFloat(numberRe.Re().FindString(k.key), 64)
+			if err != nil {
+				rest = append(rest, line)
+				continue
+			}
+			k.num = n
+		}
+		sorted = append(sorted, k)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if reverse {
+			a, b = b, a
+		}
+		if by == "numeric" {
+			return a.num < b.num
+		}
+		return a.key < b.key
+	})
+
+	unique := getBool(params, "unique")
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(input.Lines))
+	for _, k := range sorted {
+		if unique && seen[k.line] {
+			continue
+		}
+		seen[k.line] = true
+		out = append(out, k.line)
+	}
+	for _, line := range rest {
+		if unique && seen[line] {
+			continue
+		}
+		seen[line] = true
+		out = append(out, line)
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountBy(t *testing.T) {
+	input := lines(
+		"src/a.ts:1:1 error no-var",
+		"src/b.ts:2:1 error semi",
+		"src/a.ts:3:1 error semi",
+		"src/c.ts:4:1 error semi",
+		"src/a.ts:5:1 error eqeqeq",
+		"3 problems",
+	)
+	res, err := countBy(input, map[string]any{"pattern": `^(\S+?):\d+`, "top": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"3  src/a.ts", "1  src/b.ts", "and 1 more"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+	counts := res.Metadata["counts"].(map[string]int)
+	if counts["src/c.ts"] != 1 || len(counts) != 3 {
+		t.Errorf("counts metadata: %v", counts)
+	}
+
+	res, err = countBy(input, map[string]any{"pattern": `^(\S+?):\d+`, "top": 1, "overflow_msg": "+{n} files (100%)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Lines[len(res.Lines)-1]; got != "+2 files (100%)" {
+		t.Errorf("custom overflow msg: %q", got)
+	}
+}
+
+func TestCountByNamedGroupAndFormat(t *testing.T) {
+	input := lines("a.py:1:1: F401 unused", "b.py:2:1: E501 long", "c.py:3:1: F401 unused")
+	res, err := countBy(input, map[string]any{
+		"pattern": `(?P<file>\S+):\d+:\d+: (?P<key>[A-Z]\d+)`,
+		"format":  "{{.Key}} x{{.Count}}",
+		"top":     0,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"F401 x2", "E501 x1"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestCountByAlignsCounts(t *testing.T) {
+	var in []string
+	for i := 0; i < 12; i++ {
+		in = append(in, "x")
+	}
+	in = append(in, "y")
+	res, err := countBy(lines(in...), map[string]any{"pattern": `\w`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"12  x", " 1  y"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+}
+
+func TestSortLines(t *testing.T) {
+	input := lines("b 10", "a 9", "c 100", "no number", "a 9")
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   []string
+	}{
+		{"lexical", map[string]any{}, []string{"a 9", "a 9", "b 10", "c 100", "no number"}},
+		{"numeric", map[string]any{"by": "numeric"}, []string{"a 9", "a 9", "b 10", "c 100", "no number"}},
+		{"numeric reverse unique", map[string]any{"by": "numeric", "reverse": true, "unique": true}, []string{"c 100", "b 10", "a 9", "no number"}},
+		{"by capture", map[string]any{"pattern": `^(\w) \d+$`, "reverse": true}, []string{"c 100", "b 10", "a 9", "a 9", "no number"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := sortLines(input, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Lines, tt.want) {
+				t.Errorf("got %q, want %q", res.Lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortLinesRejectsUnknownMode(t *testing.T) {
+	if _, err := sortLines(lines("a"), map[string]any{"by": "natural"}); err == nil {
+		t.Error("expected error for unknown 'by'")
+	}
+}
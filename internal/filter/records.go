@@ -0,0 +1,113 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// records runs a nested pipeline, so like sections it registers itself here
+// to avoid an initialization cycle.
+func init() {
+	actions["records"] = records
+	validators["records"] = validateRecords
+}
+
+type recordsSpec struct {
+	start    *regexp.Regexp
+	pipeline Pipeline
+}
+
+// parseRecords reads the records params: exactly one of 'start' (regex) or
+// 'indent: true', and the nested 'pipeline'.
+func parseRecords(params map[string]any) (recordsSpec, error) {
+	var spec recordsSpec
+	start := getStr(params, "start")
+	indent := getBool(params, "indent")
+	switch {
+	case start != "" && indent:
+		return spec, fmt.Errorf("'start' and 'indent' are exclusive")
+	case start != "":
+		re, err := regexp.Compile(start)
+		if err != nil {
+			return spec, fmt.Errorf("start: %w", err)
+		}
+		spec.start = re
+	case !indent:
+		return spec, fmt.Errorf("missing 'start' or 'indent: true'")
+	}
+	raw, ok := params["pipeline"]
+	if !ok {
+		return spec, fmt.Errorf("missing 'pipeline' param")
+	}
+	p, err := toPipeline(raw)
+	if err != nil {
+		return spec, fmt.Errorf("pipeline: %w", err)
+	}
+	spec.pipeline = p
+	return spec, nil
+}
+
+func validateRecords(params map[string]any) error {
+	spec, err := parseRecords(params)
+	if err != nil {
+		return err
+	}
+	return validatePipeline(spec.pipeline)
+}
+
+// startsRecord reports whether line opens a record: it matches start, or,
+// in indent mode, it is neither blank nor indented.
+func (s recordsSpec) startsRecord(line string) bool {
+	if s.start != nil {
+		return s.start.MatchString(line)
+	}
+	return line != "" && line[0] != ' ' && line[0] != '\t'
+}
+
+// records joins multi-line records (an rspec failure, a compiler error with
+// its caret line, a commit with its body) into single lines, runs the nested
+// pipeline on them, and splits the results back into lines. Inside, each
+// "line" is a whole record with embedded newlines, so keep_lines keeps a
+// record when its pattern matches anywhere in it, dedup compares records,
+// and head counts records. A record runs from a line matching 'start', or
+// with 'indent: true' from a line that is not indented, to the next one.
+// Lines before the first record pass through untouched. Metadata "records"
+// is the number of input records, and the nested pipeline's metadata flows
+// on.
+func records(input ActionResult, params map[string]any) (ActionResult, error) {
+	spec, err := parseRecords(params)
+	if err != nil {
+		return input, fmt.Errorf("records: %w", err)
+	}
+
+	first := len(input.Lines)
+	for i, line := range input.Lines {
+		if spec.startsRecord(line) {
+			first = i
+			break
+		}
+	}
+	var joined []string
+	begin := first
+	for i := first + 1; i <= len(input.Lines); i++ {
+		if i == len(input.Lines) || spec.startsRecord(input.Lines[i]) {
+			joined = append(joined, strings.Join(input.Lines[begin:i], "\n"))
+			begin = i
+		}
+	}
+
+	res, err := RunPipeline(spec.pipeline, ActionResult{Lines: joined, Metadata: copyMeta(input.Metadata)})
+	if err != nil {
+		return input, fmt.Errorf("records: %w", err)
+	}
+
+	out := make([]string, 0, len(input.Lines))
+	out = append(out, input.Lines[:first]...)
+	for _, rec := range res.Lines {
+		out = append(out, strings.Split(rec, "\n")...)
+	}
+	meta := copyMeta(res.Metadata)
+	meta["records"] = len(joined)
+	return ActionResult{Lines: out, Metadata: meta}, nil
+}
@@ -0,0 +1,95 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordsStartKeepsWholeRecords(t *testing.T) {
+	input := lines(
+		"Failures:",
+		"  1) flaky",
+		"     expected 1",
+		"  2) slow",
+		"     timed out",
+		"  3) flaky again",
+		"     expected 2",
+	)
+	params := map[string]any{
+		"start": `^\s+\d+\) `,
+		"pipeline": []any{
+			map[string]any{"action": "keep_lines", "pattern": "expected"},
+			map[string]any{"action": "head", "n": 1, "overflow_msg": "+{remaining} more failures"},
+		},
+	}
+	res, err := records(input, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Failures:", "  1) flaky", "     expected 1", "+1 more failures"}
+	if !reflect.DeepEqual(res.Lines, want) {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+	if res.Metadata["records"] != 3 {
+		t.Errorf("records = %v, want 3", res.Metadata["records"])
+	}
+}
+
+func TestRecordsIndentDedup(t *testing.T) {
+	input := lines(
+		"main.go:3: unreachable code",
+		"\tafter return",
+		"",
+		"main.go:3: unreachable code",
+		"\tafter return",
+		"",
+		"main.go:9: unused result",
+	)
+	params := map[string]any{
+		"indent":   true,
+		"pipeline": []any{map[string]any{"action": "dedup"}},
+	}
+	res, err := records(input, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(res.Lines, "\n")
+	if strings.Count(got, "unreachable code") != 1 || !strings.Contains(got, "\tafter return") || !strings.Contains(got, "unused result") {
+		t.Errorf("got %q", res.Lines)
+	}
+}
+
+func TestRecordsNoStartPassesThrough(t *testing.T) {
+	input := lines("a", "b")
+	params := map[string]any{
+		"start":    "^never$",
+		"pipeline": []any{map[string]any{"action": "head", "n": 0}},
+	}
+	res, err := records(input, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res.Lines, input.Lines) {
+		t.Errorf("got %q, want %q", res.Lines, input.Lines)
+	}
+}
+
+func TestValidateRecords(t *testing.T) {
+	pipeline := []any{map[string]any{"action": "head"}}
+	for name, params := range map[string]map[string]any{
+		"neither":          {"pipeline": pipeline},
+		"both":             {"start": "x", "indent": true, "pipeline": pipeline},
+		"bad start":        {"start": "(", "pipeline": pipeline},
+		"no pipeline":      {"start": "x"},
+		"unknown action":   {"start": "x", "pipeline": []any{map[string]any{"action": "nope"}}},
+		"pipeline not map": {"indent": true, "pipeline": "head"},
+	} {
+		if err := validateRecords(params); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+	if err := validateRecords(map[string]any{"indent": true, "pipeline": pipeline}); err != nil {
+		t.Errorf("valid params: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// RedactedKey is the metadata key holding the number of secrets the redact
+// action replaced, summed across redact actions in a pipeline.
+const RedactedKey = "redacted"
+
+// redactDetector finds one kind of secret. When keepPrefix is set, the first
+// capture group (a header name or "key=") survives and only the rest of the
+// match is replaced.
+type redactDetector struct {
+	kind       string
+	re         *utils.LazyRegex
+	keepPrefix bool
+}
+
+// redactDetectors are the built-in detectors, by name. The private_key and
+// entropy detectors are handled separately: a key block spans lines, and
+// entropy is a property of a token rather than a pattern.
+var redactDetectors = []redactDetector{
+	{kind: "aws", re: utils.NewLazyRegex(`\b(?:AKIA|ASIA|AIDA|AROA)[0-9A-Z]{16}\b`)},
+	{kind: "aws", re: utils.NewLazyRegex(`(?i)(aws_secret_access_key["']?\s*[=:]\s*["']?)[A-Za-z0-9/+=]{40}`), keepPrefix: true},
+	{kind: "github", re: utils.NewLazyRegex(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "gitlab", re: utils.NewLazyRegex(`\bgl(?:pat|dt|rt|ptt)-[A-Za-z0-9_-]{20,}`)},
+	{kind: "jwt", re: utils.NewLazyRegex(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{kind: "bearer", re: utils.NewLazyRegex(`(?i)(\b(?:authorization|proxy-authorization|x-api-key|private-token)["']?\s*[:=]\s*["']?(?:(?:bearer|basic|token)\s+)?)[A-Za-z0-9._~+/=-]{8,}`), keepPrefix: true},
+	{kind: "bearer", re: utils.NewLazyRegex(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]{16,}=*`), keepPrefix: true},
+}
+
+var (
+	privateKeyBeginRe = utils.NewLazyRegex(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEndRe   = utils.NewLazyRegex(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	// entropyCandidateRe finds tokens long enough to be a generated secret.
+	entropyCandidateRe = utils.NewLazyRegex(`[A-Za-z0-9+/_=-]{32,}`)
+)
+
+// digestPrefixes mark content hashes, which are as random as any secret but
+// published: go.sum's "h1:" sums, npm's "sha512-" integrity strings and OCI
+// digests. A token they start or directly follow is not an entropy secret.
+var digestPrefixes = []string{"h1:", "sha1-", "sha256-", "sha384-", "sha512-", "sha256:", "sha512:"}
+
+// redactor holds the configuration of one redact pass.
+type redactor struct {
+	detectors   []redactDetector
+	privateKey  bool
+	entropy     bool
+	minEntropy  float64
+	replacement string
+}
+
+// defaultRedactor runs every built-in detector; a redact action without
+// params uses it.
+func defaultRedactor() *redactor {
+	return &redactor{
+		// Capped so appending custom detectors copies instead of writing
+		// into the shared built-in list.
+		detectors:   redactDetectors[:len(redactDetectors):len(redactDetectors)],
+		privateKey:  true,
+		entropy:     true,
+		minEntropy:  4.5,
+		replacement: "[REDACTED:{kind}]",
+	}
+}
+
+func (r *redactor) marker(kind string) string {
+	return strings.ReplaceAll(r.replacement, "{kind}", kind)
+}
+
+// Redact replaces secrets in lines using every built-in detector but entropy,
+// returning the redacted lines and the number of secrets replaced. The input
+// slice is not modified. It backs the global pass, which sees every command's
+// output: there the entropy detector would also take base64 payloads such as
+// kubectl's certificate data, so a filter opts into it with a redact action.
+func Redact(lines []string) ([]string, int) {
+	r := defaultRedactor()
+	r.entropy = false
+	return r.run(lines)
+}
+
+// RedactText is Redact for a whole block of text.
+func RedactText(s string) (string, int) {
+	if s == "" {
+		return s, 0
+	}
+	lines, n := Redact(strings.Split(s, "\n"))
+	return strings.Join(lines, "\n"), n
+}
+
+func (r *redactor) run(lines []string) ([]string, int) {
+	out := make([]string, 0, len(lines))
+	count := 0
+	inKey := false
+	for _, line := range lines {
+		if r.privateKey {
+			if inKey {
+				// Drop the key body; text after the END line survives.
+				loc := privateKeyEndRe.Re().FindStringIndex(line)
+				if loc == nil {
+					continue
+				}
+				inKey = false
+				line = line[loc[1]:]
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+			}
+			var n int
+			line, n, inKey = r.redactKeyBlock(line)
+			count += n
+		}
+		for _, d := range r.detectors {
+			re := d.re.Re()
+			n := len(re.FindAllStringIndex(line, -1))
+			if n == 0 {
+				continue
+			}
+			count += n
+			repl := r.marker(d.kind)
+			if d.keepPrefix {
+				repl = "${1}" + strings.ReplaceAll(repl, "$", "$$")
+			} else {
+				repl = strings.ReplaceAll(repl, "$", "$$")
+			}
+			line = re.ReplaceAllString(line, repl)
+		}
+		if r.entropy {
+			var n int
+			line, n = r.redactEntropy(line)
+			count += n
+		}
+		out = append(out, line)
+	}
+	return out, count
+}
+
+// redactKeyBlock replaces a private key block starting on line. A block that
+// ends on the same line (a key embedded in JSON with escaped newlines) is
+// replaced in place; otherwise the rest of the line is replaced and the
+// final result reports that the following lines belong to the key.
+func (r *redactor) redactKeyBlock(line string) (string, int, bool) {
+	n := 0
+	for {
+		begin := privateKeyBeginRe.Re().FindStringIndex(line)
+		if begin == nil {
+			return line, n, false
+		}
+		n++
+		rest := line[begin[1]:]
+		end := privateKeyEndRe.Re().FindStringIndex(rest)
+		if end == nil {
+			return line[:begin[0]] + r.marker("private_key"), n, true
+		}
+		line = line[:begin[0]] + r.marker("private_key") + rest[end[1]:]
+	}
+}
+
+// redactEntropy replaces the tokens of line that look random, sparing the
+// content hashes marked by digestPrefixes.
+func (r *redactor) redactEntropy(line string) (string, int) {
+	var b strings.Builder
+	n, last := 0, 0
+	for _, loc := range entropyCandidateRe.Re().FindAllStringIndex(line, -1) {
+		tok := line[loc[0]:loc[1]]
+		if isDigest(line[:loc[0]], tok) || !looksRandom(tok, r.minEntropy) {
+			continue
+		}
+		b.WriteString(line[last:loc[0]])
+		b.WriteString(r.marker("entropy"))
+		last = loc[1]
+		n++
+	}
+	if n == 0 {
+		return line, 0
+	}
+	b.WriteString(line[last:])
+	return b.String(), n
+}
+
+// isDigest reports whether tok, preceded on its line by before, is a content
+// hash rather than a secret.
+func isDigest(before, tok string) bool {
+	for _, p := range digestPrefixes {
+		if strings.HasPrefix(tok, p) || strings.HasSuffix(before, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// looksRandom reports whether tok reads like a generated secret: mixed case
+// with digits and a Shannon entropy of at least min bits per character. Hex
+// digests (git SHAs, checksums) stay below 4 bits and are left alone.
+func looksRandom(tok string, min float64) bool {
+	var upper, lower, digit bool
+	freq := make(map[rune]int)
+	for _, c := range tok {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+		freq[c]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	entropy := 0.0
+	size := float64(len(tok))
+	for _, n := range freq {
+		p := float64(n) / size
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= min
+}
+
+// redact replaces secrets with "[REDACTED:<kind>]" markers. By default every
+// built-in detector runs; 'detectors' narrows them to a subset and 'patterns'
+// adds custom regexes. Metadata "redacted" counts the replacements.
+func redact(input ActionResult, params map[string]any) (ActionResult, error) {
+	r := defaultRedactor()
+	r.minEntropy = getFloat(params, "min_entropy", r.minEntropy)
+	if r.minEntropy <= 0 {
+		return input, fmt.Errorf("redact: 'min_entropy' must be positive")
+	}
+	if s := getStr(params, "replacement"); s != "" {
+		r.replacement = s
+	}
+
+	detectors := r.detectors
+	if raw, ok := params["detectors"]; ok {
+		names, ok := toStringSlice(raw)
+		if !ok {
+			return input, fmt.Errorf("redact: 'detectors' must be a list of strings")
+		}
+		detectors, r.privateKey, r.entropy = nil, false, false
+		for _, name := range names {
+			switch name {
+			case "private_key":
+				r.privateKey = true
+			case "entropy":
+				r.entropy = true
+			default:
+				found := false
+				for _, d := range redactDetectors {
+					if d.kind == name {
+						detectors = append(detectors, d)
+						found = true
+					}
+				}
+				if !found {
+					return input, fmt.Errorf("redact: unknown detector %q", name)
+				}
+			}
+		}
+	}
+
+	if raw, ok := params["patterns"]; ok {
+		patterns, ok := toStringSlice(raw)
+		if !ok {
+			return input, fmt.Errorf("redact: 'patterns' must be a list of strings")
+		}
+		for _, p := range patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return input, fmt.Errorf("redact pattern %q: %w", p, err)
+			}
+			detectors = append(detectors, redactDetector{kind: "custom", re: utils.NewLazyRegex(p)})
+		}
+	}
+	r.detectors = detectors
+
+	lines, n := r.run(input.Lines)
+	meta := copyMeta(input.Metadata)
+	prev, _ := meta[RedactedKey].(int)
+	meta[RedactedKey] = prev + n
+	return ActionResult{Lines: lines, Metadata: meta}, nil
+}
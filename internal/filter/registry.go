@@ -214,26 +214,47 @@ func (r *Registry) Commands() []string {
 func matchesFlags(f *Filter, args []string) bool {
 	// Check exclude_flags: skip if user passed any excluded flag
 	for _, exclude := range f.Match.ExcludeFlags {
-		for _, arg := range args {
-			if strings.HasPrefix(arg, exclude) {
-				return false
-			}
+		if hasFlag(args, exclude) {
+			return false
 		}
 	}
 
 	// Check require_flags: skip if user missing required flag
 	for _, require := range f.Match.RequireFlags {
-		found := false
-		for _, arg := range args {
-			if strings.HasPrefix(arg, require) {
-				found = true
-				break
-			}
-		}
-		if !found {
+		if !hasFlag(args, require) {
 			return false
 		}
 	}
 
-	return true
+	// Check require_any_flags: skip if user passed none of them
+	if len(f.Match.RequireAnyFlags) == 0 {
+		return true
+	}
+	for _, require := range f.Match.RequireAnyFlags {
+		if hasFlag(args, require) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlag reports whether args contain flag. A plain flag matches any
+// argument it prefixes. "FLAG VALUE" matches FLAG given VALUE in each
+// spelling: "FLAG VALUE", "FLAG=VALUE" and, for a short flag, "-fVALUE".
+func hasFlag(args []string, flag string) bool {
+	name, value, withValue := strings.Cut(flag, " ")
+	short := len(name) == 2 && name[0] == '-' && name[1] != '-'
+	for i, arg := range args {
+		switch {
+		case !withValue:
+			if strings.HasPrefix(arg, flag) {
+				return true
+			}
+		case arg == name && i+1 < len(args) && args[i+1] == value,
+			arg == name+"="+value,
+			short && arg == name+value:
+			return true
+		}
+	}
+	return false
 }
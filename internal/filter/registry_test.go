@@ -58,6 +58,93 @@ func TestGhPrDiffNotCondensed(t *testing.T) {
 	}
 }
 
+// TestMvnTestFilterScopedToTestGoals verifies that only the goals that run
+// tests reach the junit filter; every other mvn invocation keeps the log
+// filter, since it may leave no report to read.
+func TestMvnTestFilterScopedToTestGoals(t *testing.T) {
+	var filters []Filter
+	for _, file := range []string{"mvn-test.yaml", "mvn.yaml"} {
+		data, err := os.ReadFile("../../filters/" + file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		f, err := ParseFilter(data)
+		if err != nil {
+			t.Fatalf("parse %s: %v", file, err)
+		}
+		filters = append(filters, *f)
+	}
+	reg := NewRegistry(filters)
+
+	tests := []struct {
+		subcommand string
+		args       []string
+		want       string
+	}{
+		{"test", nil, "mvn-test"},
+		{"verify", []string{"-pl", "core"}, "mvn-test"},
+		{"compile", nil, "mvn"},
+		{"test-compile", nil, "mvn"},
+		{"test", []string{"-DskipTests"}, "mvn"},
+		{"clean", []string{"test"}, "mvn"},
+	}
+	for _, tt := range tests {
+		got := reg.Match("mvn", tt.subcommand, tt.args)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != tt.want {
+			t.Errorf("mvn %s %v matched %q, want %q", tt.subcommand, tt.args, name, tt.want)
+		}
+	}
+}
+
+// TestYAMLFiltersScopedToYAMLOutput verifies that the yaml_extract filters
+// match only the invocations that print YAML, so other output of the same
+// subcommand never reaches the YAML parser.
+func TestYAMLFiltersScopedToYAMLOutput(t *testing.T) {
+	var filters []Filter
+	for _, file := range []string{"helm-get.yaml", "docker-compose-config.yaml", "kubectl-get-yaml.yaml"} {
+		data, err := os.ReadFile("../../filters/" + file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		f, err := ParseFilter(data)
+		if err != nil {
+			t.Fatalf("parse %s: %v", file, err)
+		}
+		filters = append(filters, *f)
+	}
+	reg := NewRegistry(filters)
+
+	tests := []struct {
+		command, subcommand string
+		args                []string
+		want                string
+	}{
+		{"helm", "get", []string{"values", "web"}, "helm-get"},
+		{"helm", "get", []string{"manifest", "web", "-n", "prod"}, "helm-get"},
+		{"helm", "get", []string{"values", "web", "-o", "json"}, ""},
+		{"helm", "get", []string{"notes", "web"}, ""},
+		{"docker", "compose", []string{"-f", "prod.yml", "config"}, "docker-compose-config"},
+		{"docker", "compose", []string{"config", "--services"}, ""},
+		{"docker", "compose", []string{"up", "-d"}, ""},
+		{"kubectl", "get", []string{"deploy", "web", "-oyaml"}, "kubectl-get-yaml"},
+		{"kubectl", "get", []string{"pods"}, ""},
+	}
+	for _, tt := range tests {
+		got := reg.Match(tt.command, tt.subcommand, tt.args)
+		name := ""
+		if got != nil {
+			name = got.Name
+		}
+		if name != tt.want {
+			t.Errorf("%s %s %v matched %q, want %q", tt.command, tt.subcommand, tt.args, name, tt.want)
+		}
+	}
+}
+
 func TestPackageManagerInstallFiltersScopedToDependencyChangingCommands(t *testing.T) {
 	files := []string{"npm-install.yaml", "pnpm-install.yaml", "yarn-install.yaml"}
 	filters := make([]Filter, 0, len(files))
@@ -318,6 +405,31 @@ func TestRegistryMatchRequireFlags(t *testing.T) {
 	}
 }
 
+func TestRegistryMatchRequireAnyFlagValues(t *testing.T) {
+	f := Filter{
+		Name:    "get-yaml",
+		Version: 1,
+		Match:   Match{Command: "kubectl", Subcommand: NewSubcommand("get"), RequireAnyFlags: []string{"-o yaml", "--output yaml"}},
+	}
+	reg := NewRegistry([]Filter{f})
+
+	for _, args := range [][]string{
+		{"pods", "-o", "yaml"}, {"pods", "-oyaml"}, {"pods", "-o=yaml"},
+		{"pods", "--output", "yaml"}, {"pods", "--output=yaml"},
+	} {
+		if reg.Match("kubectl", "get", args) == nil {
+			t.Errorf("expected match for %q", args)
+		}
+	}
+	for _, args := range [][]string{
+		{"pods"}, {"pods", "-o", "json"}, {"yaml-config"}, {"pods", "-o", "yamlish"}, {"pods", "--output=yaml2"},
+	} {
+		if reg.Match("kubectl", "get", args) != nil {
+			t.Errorf("expected no match for %q", args)
+		}
+	}
+}
+
 func TestRegistryCommands(t *testing.T) {
 	filters := []Filter{
 		makeFilter("git-log", "git", "log"),
@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// sections runs nested pipelines, which look actions up in the registry, so
+// it registers itself here rather than in the literals to avoid an
+// initialization cycle.
+func init() {
+	actions["sections"] = sections
+	validators["sections"] = validateSections
+}
+
+// preambleSection names the lines that come before the first header.
+const preambleSection = "preamble"
+
+type sectionSpec struct {
+	name     string
+	start    *regexp.Regexp
+	pipeline Pipeline
+}
+
+// parseSections reads the "sections" param: a list of {name, start, pipeline}
+// maps. A section without start configures the preamble.
+func parseSections(params map[string]any) ([]sectionSpec, error) {
+	raw, ok := params["sections"]
+	if !ok {
+		return nil, fmt.Errorf("missing 'sections' param")
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'sections' must be a list")
+	}
+	specs := make([]sectionSpec, 0, len(list))
+	preamble := false
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("sections[%d] must be a map", i)
+		}
+		spec := sectionSpec{name: getStr(m, "name")}
+		if spec.name == "" {
+			return nil, fmt.Errorf("sections[%d] missing 'name'", i)
+		}
+		if start := getStr(m, "start"); start != "" {
+			re, err := regexp.Compile(start)
+			if err != nil {
+				return nil, fmt.Errorf("sections[%d] start: %w", i, err)
+			}
+			spec.start = re
+		} else {
+			if preamble {
+				return nil, fmt.Errorf("sections[%d] missing 'start' (only one preamble section is allowed)", i)
+			}
+			preamble = true
+		}
+		if p, ok := m["pipeline"]; ok {
+			pl, err := toPipeline(p)
+			if err != nil {
+				return nil, fmt.Errorf("sections[%d] pipeline: %w", i, err)
+			}
+			spec.pipeline = pl
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+func validateSections(params map[string]any) error {
+	specs, err := parseSections(params)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if err := validatePipeline(spec.pipeline); err != nil {
+			return fmt.Errorf("section %q: %w", spec.name, err)
+		}
+	}
+	return nil
+}
+
+// sections splits the input into named sections at header lines, runs each
+// section's nested pipeline on it, and reassembles the results in order. A
+// header belongs to the section it opens; a header regex that matches again
+// opens another instance of the same section. Lines before the first header
+// form the preamble, which passes through unless a section without 'start'
+// gives it a pipeline. Metadata "sections" maps each name to the number of
+// input lines it held.
+func sections(input ActionResult, params map[string]any) (ActionResult, error) {
+	specs, err := parseSections(params)
+	if err != nil {
+		return input, fmt.Errorf("sections: %w", err)
+	}
+
+	preamble := &sectionSpec{name: preambleSection}
+	for i := range specs {
+		if specs[i].start == nil {
+			preamble = &specs[i]
+		}
+	}
+
+	type chunk struct {
+		spec  *sectionSpec
+		start int
+	}
+	chunks := []chunk{{spec: preamble}}
+	for n, line := range input.Lines {
+		for i := range specs {
+			if specs[i].start != nil && specs[i].start.MatchString(line) {
+				chunks = append(chunks, chunk{spec: &specs[i], start: n})
+				break
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	var out []string
+	for i, c := range chunks {
+		end := len(input.Lines)
+		if i+1 < len(chunks) {
+			end = chunks[i+1].start
+		}
+		if end == c.start {
+			continue
+		}
+		counts[c.spec.name] += end - c.start
+		// The three-index slice caps capacity, so an action that appends to
+		// its input cannot write into the next section's lines.
+		part := ActionResult{Lines: input.Lines[c.start:end:end], Metadata: copyMeta(input.Metadata)}
+		res, err := RunPipeline(c.spec.pipeline, part)
+		if err != nil {
+			return input, fmt.Errorf("sections: section %q: %w", c.spec.name, err)
+		}
+		out = append(out, res.Lines...)
+	}
+
+	meta := copyMeta(input.Metadata)
+	meta["sections"] = counts
+	return ActionResult{Lines: out, Metadata: meta}, nil
+}
+
+// toPipeline converts a nested pipeline decoded from YAML (a list of maps
+// with an "action" key and inline params) into a Pipeline.
+func toPipeline(v any) (Pipeline, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("must be a list of actions")
+	}
+	p := make(Pipeline, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("pipeline[%d] must be a map", i)
+		}
+		name := getStr(m, "action")
+		params := make(map[string]any, len(m))
+		for k, val := range m {
+			if k != "action" {
+				params[k] = val
+			}
+		}
+		p = append(p, Action{ActionName: name, Params: params})
+	}
+	return p, nil
+}
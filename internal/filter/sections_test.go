@@ -0,0 +1,136 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+const pytestSectionsYAML = `
+name: "pytest-sections"
+match:
+  command: "pytest"
+pipeline:
+  - action: "sections"
+    sections:
+      - name: "header"
+        pipeline:
+          - action: "remove_lines"
+            pattern: "."
+      - name: "failures"
+        start: "^=+ FAILURES =+$"
+        pipeline:
+          - action: "keep_lines"
+            pattern: "^(_{3,}|E )"
+      - name: "summary"
+        start: "^=+ short test summary"
+  - action: "format_template"
+    template: "{{.lines}}\n({{.sections.failures}} failure lines)"
+`
+
+func TestSections(t *testing.T) {
+	f, err := ParseFilter([]byte(pytestSectionsYAML))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	input := lines(
+		"===== test session starts =====",
+		"collected 3 items",
+		"test_a.py .F.",
+		"===== FAILURES =====",
+		"_____ test_two _____",
+		"    def test_two():",
+		">       assert 1 == 2",
+		"E       assert 1 == 2",
+		"===== short test summary info =====",
+		"FAILED test_a.py::test_two - assert 1 == 2",
+	)
+	res, err := RunPipeline(f.Pipeline, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"_____ test_two _____",
+		"E       assert 1 == 2",
+		"===== short test summary info =====",
+		"FAILED test_a.py::test_two - assert 1 == 2",
+		"(5 failure lines)",
+	}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(res.Lines, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSectionsRepeatedHeaderAndDefaultPreamble(t *testing.T) {
+	input := lines("Compiling a", "warning: x", "  --> a.rs:1", "warning: y", "  --> b.rs:2", "Finished")
+	res, err := sections(input, map[string]any{
+		"sections": []any{
+			map[string]any{
+				"name":     "warning",
+				"start":    "^warning",
+				"pipeline": []any{map[string]any{"action": "head", "n": 1, "overflow_msg": "..."}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each header opens its own instance that runs to the next header, so
+	// "Finished" belongs to the last warning; the preamble passes through.
+	want := []string{"Compiling a", "warning: x", "...", "warning: y", "..."}
+	if strings.Join(res.Lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", res.Lines, want)
+	}
+	counts, _ := res.Metadata["sections"].(map[string]int)
+	if counts["warning"] != 5 || counts["preamble"] != 1 {
+		t.Errorf("section counts = %v", counts)
+	}
+}
+
+func TestSectionsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown nested action", `
+name: "bad"
+match:
+  command: "x"
+pipeline:
+  - action: "sections"
+    sections:
+      - name: "a"
+        start: "^a"
+        pipeline:
+          - action: "nope"
+`, `unknown action "nope"`},
+		{"bad start regex", `
+name: "bad"
+match:
+  command: "x"
+pipeline:
+  - action: "sections"
+    sections:
+      - name: "a"
+        start: "("
+`, "start"},
+		{"two preambles", `
+name: "bad"
+match:
+  command: "x"
+pipeline:
+  - action: "sections"
+    sections:
+      - name: "a"
+      - name: "b"
+`, "only one preamble"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFilter([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
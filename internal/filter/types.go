@@ -71,6 +71,8 @@ type Match struct {
 	Subcommand   MatchSubcommand `yaml:"subcommand,omitempty"`
 	ExcludeFlags []string        `yaml:"exclude_flags,omitempty"`
 	RequireFlags []string        `yaml:"require_flags,omitempty"`
+	// RequireAnyFlags lists flags of which at least one must be present.
+	RequireAnyFlags []string `yaml:"require_any_flags,omitempty"`
 }
 
 // MatchSubcommand preserves whether match.subcommand was omitted while
@@ -146,7 +148,22 @@ type Inject struct {
 	Args          []string          `yaml:"args,omitempty"`
 	Defaults      map[string]string `yaml:"defaults,omitempty"`
 	SkipIfPresent []string          `yaml:"skip_if_present,omitempty"`
-}
+	// Reports lists globs of test report files to read after the run, such as
+	// target/surefire-reports/*.xml. Only files modified since the command
+	// started are used. A ReportPlaceholder in Args is replaced with a fresh
+	// temporary path whose file is read as well, and a ReportDirPlaceholder
+	// with a fresh directory whose files are all read.
+	Reports []string `yaml:"reports,omitempty"`
+}
+
+// ReportPlaceholder in inject args is replaced with a temporary report path,
+// e.g. "--junitxml={report}".
+const ReportPlaceholder = "{report}"
+
+// ReportDirPlaceholder in inject args is replaced with a temporary directory,
+// for tools that name their own report files, e.g. dotnet test's
+// "--results-directory {report_dir}".
+const ReportDirPlaceholder = "{report_dir}"
 
 // Action represents a single step in a filter pipeline.
 type Action struct {
@@ -0,0 +1,225 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultYAMLDrop is the noise yaml_extract removes when no 'drop' list is
+// given: server-side bookkeeping Kubernetes adds to every object. The object
+// fields are anchored on metadata, at any depth so the items of a kind: List
+// are cleaned too, but a ConfigMap's data.uid or a CRD's spec.generation is
+// left alone.
+var defaultYAMLDrop = []string{
+	"**.metadata.managedFields",
+	"**.metadata.annotations.kubectl.kubernetes.io/*",
+	"**.metadata.annotations.deployment.kubernetes.io/*",
+	"**.metadata.resourceVersion",
+	"**.metadata.uid",
+	"**.metadata.selfLink",
+	"**.metadata.generation",
+	"**.metadata.creationTimestamp",
+	"**.conditions[*].lastTransitionTime",
+	"**.conditions[*].lastProbeTime",
+	"**.conditions[*].lastHeartbeatTime",
+	"**.conditions[*].lastUpdateTime",
+}
+
+// compileKeyPath turns a key-path glob into a regexp over dotted paths such
+// as "status.conditions[0].type". "*" matches within one key, "[*]" matches
+// any index, and "**" matches any run of keys ("**." may match none).
+func compileKeyPath(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**."):
+			b.WriteString(`(?:.*\.)?`)
+			i += 3
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(`.*`)
+			i += 2
+		case strings.HasPrefix(pattern[i:], "[*]"):
+			b.WriteString(`\[\d+\]`)
+			i += 3
+		case pattern[i] == '*':
+			b.WriteString(`[^.\[]*`)
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			i++
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func compileKeyPaths(params map[string]any, key string, def []string) ([]*regexp.Regexp, error) {
+	patterns := def
+	if raw, ok := params[key]; ok {
+		var ok bool
+		if patterns, ok = toStringSlice(raw); !ok {
+			return nil, fmt.Errorf("'%s' must be a list of strings", key)
+		}
+	}
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := compileKeyPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s path %q: %w", key, p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func matchAnyPath(res []*regexp.Regexp, path string) bool {
+	for _, re := range res {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlPruner applies yaml_extract's allow and deny lists to a node tree.
+type yamlPruner struct {
+	keep, drop []*regexp.Regexp
+}
+
+// prune filters n in place and reports whether anything of it survives.
+// kept is true once an ancestor matched the allow list, so the whole
+// subtree below it is kept (minus denied paths).
+func (p *yamlPruner) prune(n *yaml.Node, path string, kept bool) bool {
+	if !kept && len(p.keep) > 0 && path != "" && matchAnyPath(p.keep, path) {
+		kept = true
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			return kept || len(p.keep) == 0
+		}
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			child := key.Value
+			if path != "" {
+				child = path + "." + key.Value
+			}
+			if matchAnyPath(p.drop, child) {
+				continue
+			}
+			if p.prune(val, child, kept) {
+				content = append(content, key, val)
+			}
+		}
+		n.Content = content
+		return len(content) > 0
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			return kept || len(p.keep) == 0
+		}
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for i, item := range n.Content {
+			child := path + "[" + strconv.Itoa(i) + "]"
+			if matchAnyPath(p.drop, child) {
+				continue
+			}
+			if p.prune(item, child, kept) {
+				content = append(content, item)
+			}
+		}
+		n.Content = content
+		return len(content) > 0
+	}
+	return kept || len(p.keep) == 0
+}
+
+// compactYAML renders short lists of scalars in flow style ("[80, 443]").
+func compactYAML(n *yaml.Node) {
+	if n.Kind == yaml.SequenceNode && len(n.Content) > 0 {
+		width := 0
+		flow := true
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode || strings.ContainsAny(item.Value, "\n,[]{}") {
+				flow = false
+				break
+			}
+			width += len(item.Value) + 2
+		}
+		if flow && width <= 60 {
+			n.Style = yaml.FlowStyle
+			return
+		}
+	}
+	for _, c := range n.Content {
+		compactYAML(c)
+	}
+}
+
+// yamlExtract parses multi-document YAML (kubectl -o yaml, helm template,
+// docker compose config) and re-emits it compactly after removing denied key
+// paths and, when an allow list is given, everything outside it. Mappings
+// left empty by the pruning disappear, as do documents with nothing left.
+// Input that is not a YAML mapping or sequence is an error, so the engine
+// falls back to raw output.
+func yamlExtract(input ActionResult, params map[string]any) (ActionResult, error) {
+	keep, err := compileKeyPaths(params, "keep", nil)
+	if err != nil {
+		return input, fmt.Errorf("yaml_extract: %w", err)
+	}
+	drop, err := compileKeyPaths(params, "drop", defaultYAMLDrop)
+	if err != nil {
+		return input, fmt.Errorf("yaml_extract: %w", err)
+	}
+	p := &yamlPruner{keep: keep, drop: drop}
+
+	dec := yaml.NewDecoder(strings.NewReader(strings.Join(input.Lines, "\n")))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return input, fmt.Errorf("yaml_extract: parse: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue // an empty document, e.g. a stray "---"
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode && root.Kind != yaml.SequenceNode {
+			return input, fmt.Errorf("yaml_extract: input is not a YAML mapping or sequence")
+		}
+		if !p.prune(root, "", false) {
+			continue
+		}
+		compactYAML(root)
+		docs = append(docs, &doc)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return input, fmt.Errorf("yaml_extract: encode: %w", err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return input, fmt.Errorf("yaml_extract: encode: %w", err)
+	}
+
+	out := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if buf.Len() == 0 {
+		out = nil
+	}
+	return ActionResult{Lines: out, Metadata: input.Metadata}, nil
+}
@@ -0,0 +1,151 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+const podYAML = `apiVersion: v1
+kind: Pod
+metadata:
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: |
+      {"apiVersion":"v1","kind":"Pod"}
+  creationTimestamp: "2024-05-01T10:00:00Z"
+  managedFields:
+  - manager: kubectl
+    operation: Update
+  name: api-1
+  namespace: default
+  resourceVersion: "12345"
+  uid: 0b8e6c1e-2f1a-4c8e-9d2a-1f0e3b7a9c55
+spec:
+  containers:
+  - name: api
+    image: api:1.2
+    ports:
+    - 8080
+    - 9090
+status:
+  conditions:
+  - lastProbeTime: null
+    lastTransitionTime: "2024-05-01T10:00:05Z"
+    status: "True"
+    type: Ready
+  phase: Running
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+  - port: 80
+`
+
+func TestYAMLExtractDefaults(t *testing.T) {
+	res, err := yamlExtract(lines(strings.Split(podYAML, "\n")...), map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: v1
+kind: Pod
+metadata:
+  name: api-1
+  namespace: default
+spec:
+  containers:
+    - name: api
+      image: api:1.2
+      ports: [8080, 9090]
+status:
+  conditions:
+    - status: "True"
+      type: Ready
+  phase: Running
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: api
+spec:
+  ports:
+    - port: 80`
+	if got := strings.Join(res.Lines, "\n"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestYAMLExtractDefaultsKeepUserData(t *testing.T) {
+	input := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: ids
+    uid: 0b8e6c1e-2f1a-4c8e-9d2a-1f0e3b7a9c55
+    generation: 3
+  data:
+    uid: "1000"
+    resourceVersion: v2`
+	res, err := yamlExtract(lines(strings.Split(input, "\n")...), map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: ids
+    data:
+      uid: "1000"
+      resourceVersion: v2`
+	if got := strings.Join(res.Lines, "\n"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestYAMLExtractKeep(t *testing.T) {
+	res, err := yamlExtract(lines(strings.Split(podYAML, "\n")...), map[string]any{
+		"keep": []any{"kind", "metadata.name", "spec.containers[*].image", "status.phase"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `kind: Pod
+metadata:
+  name: api-1
+spec:
+  containers:
+    - image: api:1.2
+status:
+  phase: Running
+---
+kind: Service
+metadata:
+  name: api`
+	if got := strings.Join(res.Lines, "\n"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestYAMLExtractCustomDrop(t *testing.T) {
+	input := lines("a:", "  b: 1", "  c: 2", "d: [1, 2]")
+	res, err := yamlExtract(input, map[string]any{"drop": []any{"a.*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(res.Lines, "\n"); got != "d: [1, 2]" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestYAMLExtractRejectsNonYAML(t *testing.T) {
+	input := lines("NAME    READY   STATUS    RESTARTS", "api-1   1/1     Running   0")
+	if _, err := yamlExtract(input, map[string]any{}); err == nil {
+		t.Error("expected error for table output")
+	}
+}
@@ -60,7 +60,7 @@ func RunCodex(r io.Reader, w io.Writer, commands []string, prefixes []Transparen
 		cmdSet[c] = struct{}{}
 	}
 
-	res := RewriteCommand(ti.Command, cmdSet, prefixes, snipBin)
+	res := RewriteSessionCommand(ti.Command, cmdSet, prefixes, snipBin, Session{Agent: codexAgent, ID: input.SessionID})
 	if !res.Changed || !res.AllKnown {
 		if audit {
 			base := firstBase(ti.Command)
@@ -27,6 +27,18 @@ const copilotAgent = "copilot"
 type copilotInput struct {
 	ToolInput json.RawMessage `json:"tool_input"`
 	ToolArgs  json.RawMessage `json:"toolArgs"`
+	// VS Code names the session sessionId; Claude Code-style payloads use
+	// session_id.
+	SessionID      string `json:"sessionId"`
+	SessionIDSnake string `json:"session_id"`
+}
+
+// session returns the payload's session ID, in either spelling.
+func (in copilotInput) session() string {
+	if in.SessionID != "" {
+		return in.SessionID
+	}
+	return in.SessionIDSnake
 }
 
 // RunCopilot reads a GitHub Copilot CLI / VS Code PreToolUse payload from r,
@@ -79,7 +91,7 @@ func RunCopilot(r io.Reader, w io.Writer, commands []string, prefixes []Transpar
 		cmdSet[c] = struct{}{}
 	}
 
-	res := RewriteCommand(command, cmdSet, prefixes, snipBin)
+	res := RewriteSessionCommand(command, cmdSet, prefixes, snipBin, Session{Agent: copilotAgent, ID: input.session()})
 	if !res.Changed {
 		if audit {
 			base := firstBase(command)
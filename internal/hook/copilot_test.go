@@ -345,3 +345,21 @@ func TestRunCopilotMalformedJSON(t *testing.T) {
 		t.Errorf("expected no output for malformed JSON, got: %s", out.String())
 	}
 }
+
+// TestRunCopilotSessionID verifies that the VS Code payload's sessionId
+// reaches the rewritten command with the copilot agent name.
+func TestRunCopilotSessionID(t *testing.T) {
+	data, _ := json.Marshal(map[string]any{
+		"sessionId":  "vs-42",
+		"tool_name":  "run_in_terminal",
+		"tool_input": map[string]any{"command": "git log -10"},
+	})
+	var out bytes.Buffer
+	if err := RunCopilot(bytes.NewReader(data), &out, []string{"git"}, nil, "/usr/local/bin/snip"); err != nil {
+		t.Fatalf("RunCopilot: %v", err)
+	}
+	want := `"/usr/local/bin/snip" --agent copilot --session vs-42 run -- git log -10`
+	if got := copilotUpdatedCommand(t, out.String()); got != want {
+		t.Errorf("rewritten = %q, want %q", got, want)
+	}
+}
@@ -9,8 +9,12 @@ import (
 	"github.com/edouard-claude/snip/internal/hookaudit"
 )
 
+// claudeAgent is the agent name tracking records for Claude Code commands.
+const claudeAgent = "claude-code"
+
 // hookInput represents the JSON payload from Claude Code PreToolUse.
 type hookInput struct {
+	SessionID string          `json:"session_id"`
 	ToolName  string          `json:"tool_name"`
 	ToolInput json.RawMessage `json:"tool_input"`
 }
@@ -68,7 +72,7 @@ func Run(r io.Reader, w io.Writer, commands []string, prefixes []TransparentPref
 	}
 
 	// Rewrite every runnable segment whose base command snip supports.
-	res := RewriteCommand(ti.Command, cmdSet, prefixes, snipBin)
+	res := RewriteSessionCommand(ti.Command, cmdSet, prefixes, snipBin, Session{Agent: claudeAgent, ID: input.SessionID})
 	if !res.Changed {
 		// Audit: nothing matched (or already rewritten).
 		if audit {
@@ -374,3 +374,36 @@ func TestRunTransparentPrefix(t *testing.T) {
 		}
 	})
 }
+
+// TestRunSessionID verifies that the payload's session_id reaches the
+// rewritten command, with the agent name, for tracking to record.
+func TestRunSessionID(t *testing.T) {
+	commands := []string{"git"}
+	snipBin := "/usr/local/bin/snip"
+
+	cases := []struct {
+		name    string
+		session string
+		want    string
+	}{
+		{"plain id", "abc-123", `"/usr/local/bin/snip" --agent claude-code --session abc-123 run -- git log -10`},
+		{"unsafe id is not embedded", "abc; rm -rf /", `"/usr/local/bin/snip" run -- git log -10`},
+		{"no id", "", `"/usr/local/bin/snip" run -- git log -10`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, _ := json.Marshal(map[string]any{
+				"session_id": tc.session,
+				"tool_name":  "Bash",
+				"tool_input": map[string]any{"command": "git log -10"},
+			})
+			var out bytes.Buffer
+			if err := Run(bytes.NewReader(data), &out, commands, nil, snipBin); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := extractRewrittenCommand(t, out.String()); got != tc.want {
+				t.Errorf("rewritten = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -64,7 +64,7 @@ func RunPi(r io.Reader, w io.Writer, commands []string, prefixes []TransparentPr
 		cmdSet[c] = struct{}{}
 	}
 
-	res := RewriteCommand(ti.Command, cmdSet, prefixes, snipBin)
+	res := RewriteSessionCommand(ti.Command, cmdSet, prefixes, snipBin, Session{Agent: piAgent, ID: input.SessionID})
 	if !res.Changed {
 		if audit {
 			base := firstBase(ti.Command)
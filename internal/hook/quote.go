@@ -7,6 +7,13 @@ import (
 	"strings"
 )
 
+// Session identifies the agent session a hook payload came from, for
+// tracking to record with each command it rewrites.
+type Session struct {
+	Agent string
+	ID    string
+}
+
 // runInvocation renders `<quotedBin> [--plugin-config <path>] run -- ` for a
 // rewritten or suggested command. The hook process may carry a plugin
 // configuration through SNIP_PLUGIN_CONFIG (set by an agent plugin's hook
@@ -15,13 +22,40 @@ import (
 // flag keeps the plugin layer alive at run time (issue #169). A flag is
 // used instead of a `VAR=x` env prefix so PowerShell keeps working (#150).
 func runInvocation(quotedBin string) string {
+	return sessionInvocation(quotedBin, Session{})
+}
+
+// sessionInvocation is runInvocation for a command an agent session runs:
+// `--agent <name> --session <id>` reach tracking the way the plugin
+// configuration does. The session comes from the payload, so it is embedded
+// only when both are plain words that need no quoting in any shell.
+func sessionInvocation(quotedBin string, s Session) string {
 	inv := quotedBin
 	if p := os.Getenv("SNIP_PLUGIN_CONFIG"); p != "" {
 		inv += " --plugin-config " + quoteSnipBin(p)
 	}
+	if s.ID != "" && isPlainWord(s.Agent) && isPlainWord(s.ID) {
+		inv += " --agent " + s.Agent + " --session " + s.ID
+	}
 	return inv + " run -- "
 }
 
+// isPlainWord reports whether s is a non-empty run of at most 128 ASCII
+// letters, digits, '.', '_' and '-'.
+func isPlainWord(s string) bool {
+	if s == "" || len(s) > 128 {
+		return false
+	}
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // quoteSnipBin renders the snip binary path for inclusion in a rewritten
 // command, so a path containing spaces still executes as one word.
 func quoteSnipBin(path string) string {
@@ -52,7 +52,15 @@ type RewriteResult struct {
 // (HasUnverifiableConstruct) before calling this, so cmd here is free of command
 // substitution and carriage returns.
 func RewriteCommand(cmd string, cmdSet map[string]struct{}, prefixes []TransparentPrefix, snipBin string) RewriteResult {
+	return RewriteSessionCommand(cmd, cmdSet, prefixes, snipBin, Session{})
+}
+
+// RewriteSessionCommand is RewriteCommand for a command an agent session
+// runs: when the session has an ID, each wrapped snip is told the agent and
+// session, so tracking records who ran the command.
+func RewriteSessionCommand(cmd string, cmdSet map[string]struct{}, prefixes []TransparentPrefix, snipBin string, session Session) RewriteResult {
 	quotedBin := quoteSnipBin(snipBin)
+	invocation := sessionInvocation(quotedBin, session)
 
 	var b strings.Builder
 	b.Grow(len(cmd) + 32)
@@ -72,7 +80,7 @@ func RewriteCommand(cmd string, cmdSet map[string]struct{}, prefixes []Transpare
 				allKnown = false
 			}
 		} else {
-			out, headKnown, hasTail := rewriteGroup(group, cmdSet, prefixes, quotedBin, snipBin)
+			out, headKnown, hasTail := rewriteGroupAs(group, cmdSet, prefixes, quotedBin, snipBin, invocation)
 			b.WriteString(out)
 			if out != group {
 				changed = true
@@ -241,6 +249,12 @@ func RewriteCommand(cmd string, cmdSet map[string]struct{}, prefixes []Transpare
 // the head is a known/attested base command, and whether the group has a
 // non-empty pipeline tail (extra stages that were left uninspected).
 func rewriteGroup(group string, cmdSet map[string]struct{}, prefixes []TransparentPrefix, quotedBin, snipBin string) (out string, headKnown, hasTail bool) {
+	return rewriteGroupAs(group, cmdSet, prefixes, quotedBin, snipBin, runInvocation(quotedBin))
+}
+
+// rewriteGroupAs is rewriteGroup wrapping the head in invocation, the
+// rendered `snip ... run -- ` of the session.
+func rewriteGroupAs(group string, cmdSet map[string]struct{}, prefixes []TransparentPrefix, quotedBin, snipBin, invocation string) (out string, headKnown, hasTail bool) {
 	head, tail := splitFirstPipe(group)
 	hasTail = strings.TrimSpace(tail) != ""
 
@@ -281,7 +295,7 @@ func rewriteGroup(group string, cmdSet map[string]struct{}, prefixes []Transpare
 			if feedsConsumer {
 				return group, true, hasTail
 			}
-			wrappedHead := prefix + envVars + tp.Prefix + " " + before + runInvocation(quotedBin) + rest[len(before):]
+			wrappedHead := prefix + envVars + tp.Prefix + " " + before + invocation + rest[len(before):]
 			return wrappedHead + tail, true, hasTail
 		}
 	}
@@ -295,7 +309,7 @@ func rewriteGroup(group string, cmdSet map[string]struct{}, prefixes []Transpare
 		return group, true, hasTail
 	}
 
-	wrappedHead := prefix + envVars + runInvocation(quotedBin) + bareCmd
+	wrappedHead := prefix + envVars + invocation + bareCmd
 	return wrappedHead + tail, true, hasTail
 }
 
@@ -0,0 +1,192 @@
+// Package report renders the tracked token savings as a single static HTML
+// page, for sharing with people who do not run snip: CSS and SVG charts are
+// inline, and the page loads nothing from the network.
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/edouard-claude/snip/internal/economics"
+	"github.com/edouard-claude/snip/internal/tracking"
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// Chart geometry, in SVG user units.
+const (
+	chartWidth  = 760
+	labelWidth  = 240
+	valueWidth  = 80
+	rowHeight   = 24
+	trendHeight = 180
+)
+
+// hbar is one row of a horizontal bar chart.
+type hbar struct {
+	Label string
+	Value string
+	Y     float64
+	Width float64
+}
+
+type hchart struct {
+	Height float64
+	Bars   []hbar
+}
+
+// vbar is one day of the trend chart.
+type vbar struct {
+	Title  string
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+type kpi struct {
+	Label string
+	Value string
+}
+
+type page struct {
+	Generated string
+	KPIs      []kpi
+	Trend     []vbar
+	TrendFrom string
+	TrendTo   string
+	Commands  hchart
+	Filters   hchart
+	Tiers     hchart
+	TrendDays int
+	TierBasis string
+	BarX      int
+	Width     int
+	TrendH    int
+}
+
+// WriteHTML writes the report for the data in tracker, pricing the saved
+// tokens at tiers, with now as the generation time.
+func WriteHTML(w io.Writer, tracker *tracking.Tracker, tiers []economics.Tier, now time.Time) error {
+	summary, err := tracker.GetSummary()
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	const trendDays = 30
+	daily, err := tracker.GetDaily(trendDays)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	byCmd, err := tracker.GetByCommand(10)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	byFilter, err := tracker.GetBreakdown("filter", tracking.Scope{}, 15)
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+
+	p := page{
+		Generated: now.Format("2006-01-02 15:04"),
+		TrendDays: trendDays,
+		BarX:      labelWidth + 8,
+		Width:     chartWidth,
+		TrendH:    trendHeight,
+		KPIs: []kpi{
+			{"Commands filtered", fmt.Sprintf("%d", summary.TotalCommands)},
+			{"Tokens saved", utils.FormatTokens(summary.TotalSaved)},
+			{"Average savings", fmt.Sprintf("%.1f%%", summary.AvgSavings)},
+			{"Time in filtered commands", fmt.Sprintf("%.1fs", float64(summary.TotalTimeMs)/1000)},
+		},
+		TierBasis: utils.FormatTokens(summary.TotalSaved),
+	}
+
+	// Daily stats come newest first; the chart reads left to right.
+	if len(daily) > 0 {
+		p.TrendFrom, p.TrendTo = daily[len(daily)-1].Day, daily[0].Day
+		maxSaved := 1
+		for _, d := range daily {
+			maxSaved = max(maxSaved, d.SavedTokens)
+		}
+		slot := float64(chartWidth) / float64(len(daily))
+		trend := make([]vbar, 0, len(daily))
+		for i := len(daily) - 1; i >= 0; i-- {
+			d := daily[i]
+			h := float64(max(d.SavedTokens, 0)) / float64(maxSaved) * (trendHeight - 4)
+			trend = append(trend, vbar{
+				Title:  fmt.Sprintf("%s: %s tokens saved, %d commands, %.0f%%", d.Day, utils.FormatTokens(d.SavedTokens), d.Commands, d.AvgSavings),
+				X:      float64(len(trend))*slot + slot*0.1,
+				Y:      trendHeight - h,
+				Width:  slot * 0.8,
+				Height: h,
+			})
+		}
+		p.Trend = trend
+	}
+
+	labels := make([]string, len(byCmd))
+	values := make([]float64, len(byCmd))
+	texts := make([]string, len(byCmd))
+	for i, c := range byCmd {
+		labels[i], values[i] = c.Command, float64(c.SavedTokens)
+		texts[i] = fmt.Sprintf("%s (%.0f%%)", utils.FormatTokens(c.SavedTokens), c.AvgSavings)
+	}
+	p.Commands = newHChart(labels, values, texts)
+
+	labels = make([]string, len(byFilter))
+	values = make([]float64, len(byFilter))
+	texts = make([]string, len(byFilter))
+	for i, f := range byFilter {
+		labels[i], values[i] = f.Group, float64(f.SavedTokens)
+		texts[i] = fmt.Sprintf("%s (%.0f%%)", utils.FormatTokens(f.SavedTokens), f.AvgSavings)
+	}
+	p.Filters = newHChart(labels, values, texts)
+
+	labels = make([]string, len(tiers))
+	values = make([]float64, len(tiers))
+	texts = make([]string, len(tiers))
+	for i, t := range tiers {
+		cost := economics.CostForTokens(summary.TotalSaved, t.PriceM)
+		labels[i], values[i], texts[i] = t.Name, cost, economics.FormatCost(cost)
+	}
+	p.Tiers = newHChart(labels, values, texts)
+
+	return pageTemplate.Execute(w, p)
+}
+
+// newHChart lays out one bar per label, scaled to the largest value.
+func newHChart(labels []string, values []float64, texts []string) hchart {
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = max(maxValue, v)
+	}
+	barSpace := float64(chartWidth - labelWidth - 8 - valueWidth)
+	bars := make([]hbar, len(labels))
+	for i, label := range labels {
+		label = utils.Truncate(label, 32)
+		width := 0.0
+		if maxValue > 0 && values[i] > 0 {
+			width = values[i] / maxValue * barSpace
+		}
+		bars[i] = hbar{Label: label, Value: texts[i], Y: float64(i * rowHeight), Width: width}
+	}
+	return hchart{Height: float64(len(labels) * rowHeight), Bars: bars}
+}
+
+// chartSection is the data of the "hchart" template.
+type chartSection struct {
+	Title string
+	Chart hchart
+	Class string
+	Page  page
+	BarXf float64
+}
+
+var pageTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"add": func(a, b float64) float64 { return a + b },
+	"f":   func(v float64) string { return fmt.Sprintf("%.1f", v) },
+	"chart": func(title string, c hchart, class string, p page) chartSection {
+		return chartSection{Title: title, Chart: c, Class: class, Page: p, BarXf: float64(p.BarX)}
+	},
+}).Parse(pageHTML))
@@ -0,0 +1,68 @@
+//go:build !lite
+
+package report
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/edouard-claude/snip/internal/economics"
+	"github.com/edouard-claude/snip/internal/tracking"
+)
+
+func TestWriteHTMLIsSelfContained(t *testing.T) {
+	tracker, err := tracking.NewTracker(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+	_ = tracker.TrackRecord(tracking.Record{OriginalCmd: "go test ./...", SnipCmd: "go test ./...", InputTokens: 4000, OutputTokens: 400, Filter: "go-test"})
+	_ = tracker.TrackRecord(tracking.Record{OriginalCmd: "git log <script>", SnipCmd: "git log", InputTokens: 1000, OutputTokens: 200, Filter: "git-log"})
+
+	var buf bytes.Buffer
+	tiers := []economics.Tier{{Name: "Sonnet", PriceM: 3}}
+	if err := WriteHTML(&buf, tracker, tiers, time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{"<svg", "go-test", "git-log", "Sonnet", "Generated 2026-10-18 09:30", "git log &lt;script&gt;"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	for _, external := range []string{"http://", "https://", "<script", "<link", "src="} {
+		if strings.Contains(out, external) {
+			t.Errorf("report references external content: %q", external)
+		}
+	}
+}
+
+func TestWriteHTMLNoData(t *testing.T) {
+	tracker, err := tracking.NewTracker(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, tracker, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "No commands tracked") {
+		t.Errorf("empty report should say so")
+	}
+}
+
+func TestHChartTruncatesLabelsByRune(t *testing.T) {
+	label := strings.Repeat("é", 40)
+	c := newHChart([]string{label}, []float64{1}, []string{"1"})
+	got := c.Bars[0].Label
+	if !utf8.ValidString(got) || got != strings.Repeat("é", 29)+"..." {
+		t.Errorf("label = %q", got)
+	}
+}
@@ -0,0 +1,81 @@
+package report
+
+// pageHTML is the report page. It must stay self-contained: no links,
+// scripts, fonts or images from anywhere else.
+const pageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>snip — token savings report</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; background: #f6f8fa; margin: 0; }
+main { max-width: 820px; margin: 0 auto; padding: 32px 24px; }
+h1 { font-size: 22px; margin: 0 0 4px; }
+h2 { font-size: 16px; margin: 32px 0 12px; }
+.sub { color: #656d76; margin: 0; }
+.kpis { display: grid; grid-template-columns: repeat(4, 1fr); gap: 12px; margin-top: 24px; }
+.kpi { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: 12px; }
+.kpi b { display: block; font-size: 20px; }
+.kpi span { color: #656d76; font-size: 12px; }
+section { background: #fff; border: 1px solid #d0d7de; border-radius: 6px; padding: 16px; margin-top: 16px; }
+section h2 { margin-top: 0; }
+svg { display: block; width: 100%; height: auto; }
+svg text { font-size: 12px; fill: #1f2328; dominant-baseline: middle; }
+.bar { fill: #2da44e; }
+.cost { fill: #0969da; }
+.axis { color: #656d76; font-size: 12px; display: flex; justify-content: space-between; margin-top: 4px; }
+.empty { color: #656d76; }
+footer { color: #656d76; font-size: 12px; margin-top: 24px; }
+</style>
+</head>
+<body>
+<main>
+<h1>snip — token savings report</h1>
+<p class="sub">Generated {{.Generated}}</p>
+
+<div class="kpis">
+{{- range .KPIs}}
+<div class="kpi"><b>{{.Value}}</b><span>{{.Label}}</span></div>
+{{- end}}
+</div>
+
+<section>
+<h2>Tokens saved per day, last {{.TrendDays}} days</h2>
+{{- if .Trend}}
+<svg viewBox="0 0 {{.Width}} {{.TrendH}}" role="img" aria-label="tokens saved per day">
+{{- range .Trend}}
+<rect class="bar" x="{{f .X}}" y="{{f .Y}}" width="{{f .Width}}" height="{{f .Height}}"><title>{{.Title}}</title></rect>
+{{- end}}
+</svg>
+<div class="axis"><span>{{.TrendFrom}}</span><span>{{.TrendTo}}</span></div>
+{{- else}}
+<p class="empty">No commands tracked in this period.</p>
+{{- end}}
+</section>
+
+{{template "hchart" (chart "Top commands by tokens saved" .Commands "bar" .)}}
+{{template "hchart" (chart "Tokens saved per filter" .Filters "bar" .)}}
+{{template "hchart" (chart (print "Estimated value of the " .TierBasis " tokens saved, by model tier") .Tiers "cost" .)}}
+
+<footer>Tier prices are $ per million input tokens, from [economics.tiers] or snip's defaults; saved tokens are assumed to be input tokens an agent would have read.</footer>
+</main>
+</body>
+</html>
+{{define "hchart"}}
+<section>
+<h2>{{.Title}}</h2>
+{{- if .Chart.Bars}}
+<svg viewBox="0 0 {{.Page.Width}} {{.Chart.Height}}" role="img" aria-label="{{.Title}}">
+{{- range .Chart.Bars}}
+<text x="0" y="{{f (add .Y 12)}}">{{.Label}}</text>
+<rect class="{{$.Class}}" x="{{$.Page.BarX}}" y="{{f (add .Y 4)}}" width="{{f .Width}}" height="16"></rect>
+<text x="{{f (add .Width (add $.BarXf 6))}}" y="{{f (add .Y 12)}}">{{.Value}}</text>
+{{- end}}
+</svg>
+{{- else}}
+<p class="empty">Nothing tracked yet.</p>
+{{- end}}
+</section>
+{{end}}
+`
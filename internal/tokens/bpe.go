@@ -0,0 +1,170 @@
+package tokens
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// pieceRe splits text the way cl100k-style tokenizers do before merging:
+// contractions, words with one leading non-letter, runs of up to three
+// digits, punctuation runs, newlines with their indentation, and other
+// whitespace. Go's regexp has no lookahead, so Pieces handles the
+// "\s+(?!\S)" rule by hand.
+var pieceRe = utils.NewLazyRegex(`^(?:(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+)`)
+
+// maxPiece bounds the bytes merged as one piece; longer pieces (minified
+// lines, base64 blobs) are counted in chunks so merging stays linear.
+const maxPiece = 256
+
+// Pieces calls fn with each pre-tokenized piece of s, in order.
+func Pieces(s string, fn func(piece string)) {
+	re := pieceRe.Re()
+	for len(s) > 0 {
+		loc := re.FindStringIndex(s)
+		end := 1
+		if loc != nil && loc[1] > 0 {
+			end = loc[1]
+		} else {
+			_, end = utf8.DecodeRuneInString(s)
+		}
+		m := s[:end]
+		// A run of spaces before a word leaves its last space to the word:
+		// "   foo" is "  " and " foo".
+		if end < len(s) && isBlankRun(m) {
+			if _, size := utf8.DecodeLastRuneInString(m); size < len(m) {
+				end -= size
+				m = s[:end]
+			}
+		}
+		fn(m)
+		s = s[end:]
+	}
+}
+
+func isBlankRun(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) || r == '\n' || r == '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+// BPE is a byte-level byte-pair-encoding tokenizer over a tiktoken-format
+// rank table: a lower rank merges first, and every single byte has a rank,
+// so any input can be encoded.
+type BPE struct {
+	name  string
+	ranks map[string]int
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// maxCache bounds the piece-count cache; command output repeats the same
+// words, paths and indentation constantly.
+const maxCache = 1 << 16
+
+// ParseRanks reads a tiktoken rank file: one "<base64 token> <rank>" per line.
+func ParseRanks(data []byte) (*BPE, error) {
+	b := &BPE{name: "bpe", ranks: make(map[string]int, bytes.Count(data, []byte("\n"))+1), cache: make(map[string]int)}
+	var buf []byte
+	for i := 0; len(data) > 0; i++ {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte("\n"))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		tok, rank, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("line %d: want \"<base64> <rank>\"", i+1)
+		}
+		n := base64.StdEncoding.DecodedLen(len(tok))
+		if cap(buf) < n {
+			buf = make([]byte, n)
+		}
+		n, err := base64.StdEncoding.Decode(buf[:n], tok)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		r, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		b.ranks[string(buf[:n])] = r
+	}
+	for c := 0; c < 256; c++ {
+		if _, ok := b.ranks[string([]byte{byte(c)})]; !ok {
+			return nil, fmt.Errorf("no rank for byte %#02x", c)
+		}
+	}
+	return b, nil
+}
+
+// Name implements Estimator.
+func (b *BPE) Name() string { return b.name }
+
+// Count implements Estimator.
+func (b *BPE) Count(s string) int {
+	n := 0
+	Pieces(s, func(piece string) {
+		for len(piece) > maxPiece {
+			n += b.countPiece(piece[:maxPiece])
+			piece = piece[maxPiece:]
+		}
+		n += b.countPiece(piece)
+	})
+	return n
+}
+
+func (b *BPE) countPiece(piece string) int {
+	if _, ok := b.ranks[piece]; ok {
+		return 1
+	}
+	b.mu.Lock()
+	n, ok := b.cache[piece]
+	b.mu.Unlock()
+	if ok {
+		return n
+	}
+	n = b.merge(piece)
+	b.mu.Lock()
+	if len(b.cache) >= maxCache {
+		clear(b.cache)
+	}
+	b.cache[piece] = n
+	b.mu.Unlock()
+	return n
+}
+
+// merge runs byte-pair merging over piece and returns the number of parts
+// left: repeatedly join the adjacent pair whose concatenation has the lowest
+// rank, until no pair is in the table.
+func (b *BPE) merge(piece string) int {
+	// bounds[i] is where part i starts; the last entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if r, ok := b.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (best < 0 || r < best) {
+				best, at = r, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
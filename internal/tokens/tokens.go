@@ -0,0 +1,142 @@
+// Package tokens counts tokens for snip's savings figures, budgets and
+// summaries. The estimator is pluggable: a byte-level BPE tokenizer over a
+// model's vocabulary, cl100k_base embedded in the binary by default or any
+// tiktoken-format rank file, or a fast character heuristic.
+package tokens
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/edouard-claude/snip/internal/utils"
+)
+
+// Estimator counts the tokens a model would see for a piece of text.
+type Estimator interface {
+	// Name identifies the estimator and its vocabulary, e.g. "chars4" or
+	// "bpe:cl100k_base". Tracking records it with every row.
+	Name() string
+	Count(s string) int
+}
+
+// Heuristic is the historical estimator: one token per four bytes, rounded
+// up. It is free to compute but misjudges code, JSON, non-ASCII text and
+// whitespace-heavy tables.
+type Heuristic struct{}
+
+// HeuristicName is the name Heuristic reports.
+const HeuristicName = "chars4"
+
+// Name implements Estimator.
+func (Heuristic) Name() string { return HeuristicName }
+
+// Count implements Estimator.
+func (Heuristic) Count(s string) int { return utils.EstimateTokens(s) }
+
+// EmbeddedFS is set by the main package to provide the embedded vocabulary.
+// This avoids go:embed constraints on internal packages.
+var EmbeddedFS *embed.FS
+
+// EmbeddedVocab is the vocabulary file in EmbeddedFS used when no vocab path
+// is configured: OpenAI's cl100k_base, whose pre-tokenizer Pieces follows.
+const EmbeddedVocab = "tokenizer/cl100k_base.tiktoken"
+
+var (
+	mu      sync.RWMutex
+	current Estimator = Heuristic{}
+)
+
+// SetDefault makes e the estimator Count uses.
+func SetDefault(e Estimator) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = e
+}
+
+// Default returns the estimator Count uses: Heuristic unless SetDefault
+// chose another.
+func Default() Estimator {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Count counts the tokens in s with the default estimator.
+func Count(s string) int {
+	if s == "" {
+		return 0
+	}
+	return Default().Count(s)
+}
+
+// New returns the estimator called name: "bpe" (or "") or "chars4". For
+// "bpe", vocab is the path of a tiktoken-format rank file, such as
+// o200k_base.tiktoken; empty means the embedded cl100k_base.
+func New(name, vocab string) (Estimator, error) {
+	switch name {
+	case "", "bpe":
+		if vocab == "" {
+			if EmbeddedFS == nil {
+				return nil, fmt.Errorf("tokens: no embedded vocabulary")
+			}
+			return loadEmbedded(), nil
+		}
+		data, err := os.ReadFile(vocab)
+		if err != nil {
+			return nil, fmt.Errorf("tokens: read vocabulary: %w", err)
+		}
+		b, err := ParseRanks(data)
+		if err != nil {
+			return nil, fmt.Errorf("tokens: %s: %w", vocab, err)
+		}
+		b.name = vocabName(vocab)
+		return b, nil
+	case HeuristicName:
+		return Heuristic{}, nil
+	default:
+		return nil, fmt.Errorf("tokens: unknown estimator %q (want bpe or %s)", name, HeuristicName)
+	}
+}
+
+// embeddedBPE is the estimator over the embedded vocabulary. Parsing its
+// hundred thousand ranks takes tens of milliseconds, so it happens in the
+// background while the command runs; Count waits for it.
+type embeddedBPE struct {
+	ready chan struct{}
+	bpe   *BPE
+}
+
+func loadEmbedded() *embeddedBPE {
+	e := &embeddedBPE{ready: make(chan struct{})}
+	go func() {
+		defer close(e.ready)
+		if data, err := EmbeddedFS.ReadFile(EmbeddedVocab); err == nil {
+			e.bpe, _ = ParseRanks(data)
+		}
+	}()
+	return e
+}
+
+// Name implements Estimator.
+func (e *embeddedBPE) Name() string { return vocabName(EmbeddedVocab) }
+
+// Count implements Estimator. An unreadable vocabulary, which a build with
+// the file embedded cannot produce, falls back to the heuristic.
+func (e *embeddedBPE) Count(s string) int {
+	<-e.ready
+	if e.bpe == nil {
+		return Heuristic{}.Count(s)
+	}
+	return e.bpe.Count(s)
+}
+
+// vocabName is the estimator name for a vocabulary file: "bpe:" plus its base
+// name without extension.
+func vocabName(path string) string {
+	base := filepath.Base(path)
+	return "bpe:" + strings.TrimSuffix(base, filepath.Ext(base))
+}
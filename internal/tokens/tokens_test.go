@@ -0,0 +1,129 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPieces(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"it's 12345", []string{"it", "'s", " ", "123", "45"}},
+		{"a   b", []string{"a", "  ", " b"}},
+		{"x := f(y)\n\tz", []string{"x", " :=", " f", "(y", ")\n", "\tz"}},
+		{"end  ", []string{"end", "  "}},
+	}
+	for _, tt := range tests {
+		var got []string
+		Pieces(tt.in, func(p string) { got = append(got, p) })
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Pieces(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if strings.Join(got, "") != tt.in {
+			t.Errorf("Pieces(%q) does not cover its input", tt.in)
+		}
+	}
+}
+
+// rankFile builds a tiktoken rank file: every single byte, then merges in
+// rank order.
+func rankFile(merges ...string) string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), 256+i)
+	}
+	return b.String()
+}
+
+func TestBPECount(t *testing.T) {
+	b, err := ParseRanks([]byte(rankFile("he", "ll", "hell", "llo", "hello", " w", " wo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"hello", 1},
+		{"hello world", 5}, // "hello" + " wo" "r" "l" "d"
+		{"ll", 1},
+		{"élan", 5}, // two bytes for é, no merges
+	}
+	for _, tt := range tests {
+		if got := b.Count(tt.in); got != tt.want {
+			t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+	long := strings.Repeat("z", 3*maxPiece+1)
+	if got := b.Count(long); got != len(long) {
+		t.Errorf("Count(long) = %d, want %d", got, len(long))
+	}
+}
+
+func TestParseRanksErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"missing byte": "YQ== 0\n",
+		"no rank":      "YQ==\n",
+		"bad base64":   "!!! 1\n",
+		"bad rank":     "YQ== x\n",
+	} {
+		if _, err := ParseRanks([]byte(data)); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	est, err := New("chars4", "")
+	if err != nil || est.Name() != HeuristicName {
+		t.Fatalf("New(chars4) = %v, %v", est, err)
+	}
+	if _, err := New("gpt", ""); err == nil {
+		t.Error("New(gpt): want error")
+	}
+	if _, err := New("bpe", ""); err == nil {
+		t.Error("New(bpe) with no vocabulary embedded: want error")
+	}
+
+	path := filepath.Join(t.TempDir(), "tiny.tiktoken")
+	if err := os.WriteFile(path, []byte(rankFile("ab")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	est, err = New("bpe", path)
+	if err != nil {
+		t.Fatalf("New(bpe, %s): %v", path, err)
+	}
+	if est.Name() != "bpe:tiny" || est.Count("abab") != 2 {
+		t.Errorf("got %s counting %d", est.Name(), est.Count("abab"))
+	}
+	if _, err := New("bpe", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("New(bpe, missing): want error")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	defer SetDefault(Default())
+	if Count("") != 0 || Count("abcdefgh") != 2 {
+		t.Errorf("heuristic Count = %d", Count("abcdefgh"))
+	}
+	b, err := ParseRanks([]byte(rankFile("abcdefgh")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(b)
+	if Count("abcdefgh") != 1 {
+		t.Errorf("bpe Count = %d, want 1", Count("abcdefgh"))
+	}
+}
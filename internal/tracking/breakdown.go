@@ -0,0 +1,203 @@
+package tracking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// breakdownGroups maps each GetBreakdown dimension to the SQL expression
+// that names a row's group. Rows tracked before a column existed have it
+// empty (or NULL) and fall in an explicit catch-all group. A command is
+// grouped by its base command, as baseCommand names it, so "go test ./a" and
+// "go test ./b" pair up across periods.
+var breakdownGroups = map[string]string{
+	"command": `CASE WHEN instr(trim(original_cmd), ' ') > 0 THEN substr(trim(original_cmd), 1, instr(trim(original_cmd), ' ') - 1) ELSE trim(original_cmd) END`,
+	"filter":  `CASE WHEN filter = '' THEN '(unknown)' ELSE filter END`,
+	"project": `CASE WHEN project_root = '' THEN '(none)' ELSE project_root END`,
+	"agent":   `CASE WHEN agent = '' THEN '(unknown)' ELSE agent END`,
+	"status":  `CASE WHEN exit_code IS NULL THEN 'unknown' WHEN exit_code = 0 THEN 'ok' ELSE 'failed' END`,
+	"machine": `COALESCE((SELECT NULLIF(name, '') FROM machines WHERE id = machine_id), NULLIF(machine_id, ''), '(unknown)')`,
+}
+
+// BreakdownDimensions lists the dimensions GetBreakdown groups by.
+func BreakdownDimensions() []string {
+	dims := make([]string, 0, len(breakdownGroups))
+	for d := range breakdownGroups {
+		dims = append(dims, d)
+	}
+	sort.Strings(dims)
+	return dims
+}
+
+// Scope restricts a breakdown to some of the tracked commands. Zero fields
+// match everything.
+type Scope struct {
+	// ProjectRoot matches commands run inside this repository.
+	ProjectRoot string
+	Agent       string
+	// Since and Until are the first and last days, "YYYY-MM-DD" (local
+	// time), to include.
+	Since string
+	Until string
+}
+
+// bounds returns the scope's days as UTC timestamps, the form
+// datetime('now') stores: the start of Since and the end of Until, exclusive.
+// An unset bound is "".
+func (s Scope) bounds() (from, to string, err error) {
+	const layout = "2006-01-02 15:04:05"
+	if s.Since != "" {
+		day, err := time.ParseInLocation("2006-01-02", s.Since, time.Local)
+		if err != nil {
+			return "", "", fmt.Errorf("since %q: want YYYY-MM-DD", s.Since)
+		}
+		from = day.UTC().Format(layout)
+	}
+	if s.Until != "" {
+		day, err := time.ParseInLocation("2006-01-02", s.Until, time.Local)
+		if err != nil {
+			return "", "", fmt.Errorf("until %q: want YYYY-MM-DD", s.Until)
+		}
+		to = day.AddDate(0, 0, 1).UTC().Format(layout)
+	}
+	return from, to, nil
+}
+
+// where renders the scope as a SQL condition on commands and its arguments.
+func (s Scope) where() (string, []any, error) {
+	from, to, err := s.bounds()
+	if err != nil {
+		return "", nil, err
+	}
+	conds := []string{"1 = 1"}
+	var args []any
+	if s.ProjectRoot != "" {
+		conds = append(conds, "project_root = ?")
+		args = append(args, s.ProjectRoot)
+	}
+	if s.Agent != "" {
+		conds = append(conds, "agent = ?")
+		args = append(args, s.Agent)
+	}
+	if from != "" {
+		conds = append(conds, "timestamp >= ?")
+		args = append(args, from)
+	}
+	if to != "" {
+		conds = append(conds, "timestamp < ?")
+		args = append(args, to)
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// rollupWhere is where for daily_rollups, whose days are UTC dates.
+func (s Scope) rollupWhere() (string, []any, error) {
+	from, to, err := s.bounds()
+	if err != nil {
+		return "", nil, err
+	}
+	conds := []string{"1 = 1"}
+	var args []any
+	if from != "" {
+		conds = append(conds, "day >= date(?)")
+		args = append(args, from)
+	}
+	if to != "" {
+		conds = append(conds, "day < date(?)")
+		args = append(args, to)
+	}
+	return strings.Join(conds, " AND "), args, nil
+}
+
+// GroupStats holds aggregate stats for one group of a breakdown.
+type GroupStats struct {
+	Group        string
+	Count        int
+	InputTokens  int
+	OutputTokens int
+	SavedTokens  int
+	AvgSavings   float64
+	ExecTimeMs   int64
+	// RawFallbacks counts commands whose raw output was sent because the
+	// filter failed or emptied it.
+	RawFallbacks int
+}
+
+// GetBreakdown returns stats for the commands in scope grouped by dim, one
+// of BreakdownDimensions, most tokens saved first. A limit of 0 returns
+// every group. The filter breakdown of a scope with no project or agent
+// also counts the daily rollups of expired commands, so it reaches back
+// past the commands' retention.
+func (t *Tracker) GetBreakdown(dim string, scope Scope, limit int) ([]GroupStats, error) {
+	group, ok := breakdownGroups[dim]
+	if !ok {
+		return nil, fmt.Errorf("breakdown: unknown dimension %q (want %s)", dim, strings.Join(BreakdownDimensions(), ", "))
+	}
+	where, args, err := scope.where()
+	if err != nil {
+		return nil, fmt.Errorf("breakdown: %w", err)
+	}
+	if err := t.ensureOpen(); err != nil {
+		return nil, fmt.Errorf("breakdown: %w", err)
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+
+	// group comes from breakdownGroups, never from the caller.
+	source := fmt.Sprintf(`
+SELECT
+	%s as grp,
+	COUNT(*) as count,
+	SUM(input_tokens) as input_tokens,
+	SUM(output_tokens) as output_tokens,
+	SUM(saved_tokens) as saved_tokens,
+	SUM(exec_time_ms) as exec_time_ms,
+	SUM(raw_fallback) as raw_fallbacks
+FROM commands
+WHERE %s
+GROUP BY grp`, group, where)
+	if dim == "filter" && scope.ProjectRoot == "" && scope.Agent == "" {
+		rollupWhere, rollupArgs, err := scope.rollupWhere()
+		if err != nil {
+			return nil, fmt.Errorf("breakdown: %w", err)
+		}
+		source += fmt.Sprintf(`
+UNION ALL
+SELECT %s, commands, input_tokens, output_tokens, saved_tokens, exec_time_ms, raw_fallbacks
+FROM daily_rollups
+WHERE %s`, group, rollupWhere)
+		args = append(args, rollupArgs...)
+	}
+	query := `
+SELECT
+	grp,
+	SUM(count),
+	SUM(input_tokens),
+	SUM(output_tokens),
+	SUM(saved_tokens) as saved,
+	COALESCE(SUM(saved_tokens) * 100.0 / NULLIF(SUM(input_tokens), 0), 0),
+	SUM(exec_time_ms),
+	SUM(raw_fallbacks)
+FROM (` + source + `)
+GROUP BY grp
+ORDER BY saved DESC, grp
+LIMIT ?;`
+	rows, err := t.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("breakdown: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stats []GroupStats
+	for rows.Next() {
+		var s GroupStats
+		if err := rows.Scan(&s.Group, &s.Count, &s.InputTokens, &s.OutputTokens, &s.SavedTokens, &s.AvgSavings, &s.ExecTimeMs, &s.RawFallbacks); err != nil {
+			return nil, fmt.Errorf("breakdown scan: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,68 @@
+package tracking
+
+import "sort"
+
+// Delta compares one breakdown group across two periods.
+type Delta struct {
+	Group   string
+	Base    GroupStats
+	Current GroupStats
+	// SavingsDelta is the change in savings ratio, in percentage points,
+	// and SavedDelta the change in tokens saved.
+	SavingsDelta float64
+	SavedDelta   int
+	// Regression reports a savings ratio that dropped by more than the
+	// threshold between two periods that both ran the group. For a filter
+	// it usually means the tool changed its output format.
+	Regression bool
+}
+
+// Compare breaks the base and current scopes down by dim and pairs up the
+// groups, the ones with the most tokens saved in either period first.
+// threshold is the drop in savings ratio, in percentage points, that
+// counts as a regression.
+func (t *Tracker) Compare(dim string, base, current Scope, threshold float64) ([]Delta, error) {
+	before, err := t.GetBreakdown(dim, base, 0)
+	if err != nil {
+		return nil, err
+	}
+	after, err := t.GetBreakdown(dim, current, 0)
+	if err != nil {
+		return nil, err
+	}
+	return compareGroups(before, after, threshold), nil
+}
+
+func compareGroups(before, after []GroupStats, threshold float64) []Delta {
+	byGroup := make(map[string]*Delta)
+	var deltas []*Delta
+	get := func(group string) *Delta {
+		d, ok := byGroup[group]
+		if !ok {
+			d = &Delta{Group: group}
+			byGroup[group] = d
+			deltas = append(deltas, d)
+		}
+		return d
+	}
+	for _, g := range before {
+		get(g.Group).Base = g
+	}
+	for _, g := range after {
+		get(g.Group).Current = g
+	}
+
+	out := make([]Delta, 0, len(deltas))
+	for _, d := range deltas {
+		d.SavedDelta = d.Current.SavedTokens - d.Base.SavedTokens
+		if d.Base.Count > 0 && d.Current.Count > 0 {
+			d.SavingsDelta = d.Current.AvgSavings - d.Base.AvgSavings
+			d.Regression = d.SavingsDelta < -threshold
+		}
+		out = append(out, *d)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return max(out[i].Base.SavedTokens, out[i].Current.SavedTokens) > max(out[j].Base.SavedTokens, out[j].Current.SavedTokens)
+	})
+	return out
+}
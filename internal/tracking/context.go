@@ -0,0 +1,69 @@
+package tracking
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// agentEnv maps an environment variable that an agent sets in the shell it
+// runs commands in to the agent's name, for when SNIP_AGENT is not set.
+var agentEnv = []struct{ env, agent string }{
+	{"CLAUDECODE", "claude-code"},
+	{"GEMINI_CLI", "gemini-cli"},
+	{"CURSOR_AGENT", "cursor"},
+}
+
+// DetectAgent names the agent running snip: SNIP_AGENT when set, else the
+// agent whose marker variable is in the environment, else "".
+func DetectAgent() string {
+	if a := os.Getenv("SNIP_AGENT"); a != "" {
+		return a
+	}
+	for _, e := range agentEnv {
+		if os.Getenv(e.env) != "" {
+			return e.agent
+		}
+	}
+	return ""
+}
+
+// SessionID returns SNIP_SESSION_ID, which groups the commands of one agent
+// session. The hook sets it, through snip's --session flag, from the agent's
+// payload; a plugin or wrapper can set it directly.
+func SessionID() string {
+	return os.Getenv("SNIP_SESSION_ID")
+}
+
+// ProjectRoot returns the nearest directory at or above dir holding a .git
+// entry (a directory, or a file in worktrees and submodules), or "".
+func ProjectRoot(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// fillContext fills the where and who of r that the caller left empty.
+func (r *Record) fillContext() {
+	if r.Cwd == "" {
+		r.Cwd, _ = os.Getwd()
+	}
+	if r.ProjectRoot == "" {
+		r.ProjectRoot = ProjectRoot(r.Cwd)
+	}
+	if r.Agent == "" {
+		r.Agent = DetectAgent()
+	}
+	if r.SessionID == "" {
+		r.SessionID = SessionID()
+	}
+}
@@ -0,0 +1,226 @@
+package tracking
+
+import (
+	"bufio"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// machines names the machine_id of each command; imported_rows is the
+// ledger of every row Import has added, which cleanup never touches, so a
+// row imported again after expiring is still recognised.
+const createMachinesSQL = `
+CREATE TABLE IF NOT EXISTS machines (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS imported_rows (
+	uuid TEXT PRIMARY KEY
+);
+`
+
+// sqlUUID is a random version 4 UUID, for rows tracked before rows had one.
+const sqlUUID = `lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+	substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))`
+
+// addMachineIDs gives the database a machine id, named after the host, and
+// every command a row id and that machine id.
+func addMachineIDs(tx *sql.Tx) error {
+	if err := addColumns("commands", "uuid TEXT NOT NULL DEFAULT ''", "machine_id TEXT NOT NULL DEFAULT ''")(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(createMachinesSQL); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO tracking_state (key, value) VALUES ('machine_id', ?)`, newUUID()); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO machines (id, name) SELECT value, ? FROM tracking_state WHERE key = 'machine_id'`, host); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`UPDATE commands SET machine_id = (SELECT value FROM tracking_state WHERE key = 'machine_id'), uuid = ` + sqlUUID + ` WHERE uuid = ''`)
+	return err
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// loadMachineID returns the id of the machine owning db, "" in a database
+// too new or too old to have one.
+func loadMachineID(db *sql.DB) string {
+	var id string
+	_ = db.QueryRow(`SELECT value FROM tracking_state WHERE key = 'machine_id'`).Scan(&id)
+	return id
+}
+
+// ExportRow is one line of an export: a tracked command with the machine
+// it ran on and a row id, which let Import merge it exactly once.
+type ExportRow struct {
+	UUID          string `json:"uuid"`
+	MachineID     string `json:"machine_id"`
+	Machine       string `json:"machine"`
+	Timestamp     string `json:"timestamp"`
+	OriginalCmd   string `json:"original_cmd"`
+	SnipCmd       string `json:"snip_cmd"`
+	InputTokens   int    `json:"input_tokens"`
+	OutputTokens  int    `json:"output_tokens"`
+	ExecTimeMs    int64  `json:"exec_time_ms"`
+	Redacted      int    `json:"redacted"`
+	Estimator     string `json:"estimator"`
+	Filter        string `json:"filter"`
+	FilterVersion int    `json:"filter_version"`
+	// ExitCode is null for commands tracked before exit codes were.
+	ExitCode    *int   `json:"exit_code"`
+	Cwd         string `json:"cwd"`
+	ProjectRoot string `json:"project_root"`
+	Agent       string `json:"agent"`
+	SessionID   string `json:"session_id"`
+	RawFallback bool   `json:"raw_fallback"`
+	FilterError bool   `json:"filter_error"`
+}
+
+const exportSQL = `
+SELECT c.uuid, c.machine_id, COALESCE(m.name, ''), strftime('%Y-%m-%d %H:%M:%S', c.timestamp),
+	c.original_cmd, c.snip_cmd, c.input_tokens, c.output_tokens, c.exec_time_ms, c.redacted, c.estimator,
+	c.filter, c.filter_version, c.exit_code, c.cwd, c.project_root, c.agent, c.session_id, c.raw_fallback, c.filter_error
+FROM commands c LEFT JOIN machines m ON m.id = c.machine_id
+ORDER BY c.timestamp, c.uuid;
+`
+
+const importSQL = `
+INSERT INTO commands (timestamp, original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms, redacted, estimator,
+	filter, filter_version, exit_code, cwd, project_root, agent, session_id, raw_fallback, filter_error, uuid, machine_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+// Export writes every tracked command, this machine's and imported ones,
+// as newline-delimited JSON, oldest first. It returns the number of rows.
+// Commands already folded into the daily rollups are not exported.
+func (t *Tracker) Export(w io.Writer) (int, error) {
+	if err := t.ensureOpen(); err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	rows, err := t.db.Query(exportSQL)
+	if err != nil {
+		return 0, fmt.Errorf("export: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	n := 0
+	for rows.Next() {
+		var r ExportRow
+		var exitCode sql.NullInt64
+		err := rows.Scan(&r.UUID, &r.MachineID, &r.Machine, &r.Timestamp, &r.OriginalCmd, &r.SnipCmd, &r.InputTokens, &r.OutputTokens,
+			&r.ExecTimeMs, &r.Redacted, &r.Estimator, &r.Filter, &r.FilterVersion, &exitCode, &r.Cwd, &r.ProjectRoot, &r.Agent,
+			&r.SessionID, &r.RawFallback, &r.FilterError)
+		if err != nil {
+			return n, fmt.Errorf("export scan: %w", err)
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			r.ExitCode = &code
+		}
+		if err := enc.Encode(r); err != nil {
+			return n, fmt.Errorf("export: %w", err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("export: %w", err)
+	}
+	return n, bw.Flush()
+}
+
+// ImportStats counts the rows of an import.
+type ImportStats struct {
+	Rows  int
+	Added int
+	// Duplicates were imported before; Own were tracked on this machine.
+	Duplicates int
+	Own        int
+}
+
+// Import merges rows written by Export, on this or any other machine, in
+// one transaction: a bad line imports nothing. Rows already imported and
+// this machine's own rows are skipped, so importing a file twice, or a
+// file holding rows already merged from elsewhere, counts nothing twice.
+// Imported rows feed the reports but not the Prometheus counters, which
+// describe this machine.
+func (t *Tracker) Import(r io.Reader) (ImportStats, error) {
+	var stats ImportStats
+	if err := t.writable(); err != nil {
+		return stats, fmt.Errorf("import: %w", err)
+	}
+	err := t.inTx(func(tx *sql.Tx) error {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		line := 0
+		for sc.Scan() {
+			line++
+			if len(sc.Bytes()) == 0 {
+				continue
+			}
+			var row ExportRow
+			if err := json.Unmarshal(sc.Bytes(), &row); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if row.UUID == "" || row.MachineID == "" {
+				return fmt.Errorf("line %d: missing uuid or machine_id", line)
+			}
+			if _, err := time.Parse("2006-01-02 15:04:05", row.Timestamp); err != nil {
+				return fmt.Errorf("line %d: timestamp %q: want YYYY-MM-DD HH:MM:SS", line, row.Timestamp)
+			}
+			stats.Rows++
+			if row.MachineID == t.machineID {
+				stats.Own++
+				continue
+			}
+			res, err := tx.Exec(`INSERT OR IGNORE INTO imported_rows (uuid) VALUES (?)`, row.UUID)
+			if err != nil {
+				return err
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return err
+			} else if n == 0 {
+				stats.Duplicates++
+				continue
+			}
+			if row.Machine != "" {
+				if _, err := tx.Exec(`INSERT OR IGNORE INTO machines (id, name) VALUES (?, ?)`, row.MachineID, row.Machine); err != nil {
+					return err
+				}
+			}
+			saved := row.InputTokens - row.OutputTokens
+			pct := 0.0
+			if row.InputTokens > 0 {
+				pct = float64(saved) / float64(row.InputTokens) * 100
+			}
+			_, err = tx.Exec(importSQL, row.Timestamp, row.OriginalCmd, row.SnipCmd, row.InputTokens, row.OutputTokens, saved, pct,
+				row.ExecTimeMs, row.Redacted, row.Estimator, row.Filter, row.FilterVersion, row.ExitCode, row.Cwd, row.ProjectRoot,
+				row.Agent, row.SessionID, row.RawFallback, row.FilterError, row.UUID, row.MachineID)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			stats.Added++
+		}
+		return sc.Err()
+	})
+	if err != nil {
+		return ImportStats{}, fmt.Errorf("import: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,206 @@
+package tracking
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNewerSchema reports a database migrated by a newer snip than this one.
+// The tracker still reads it but records nothing, rather than write rows in a
+// schema it does not know.
+var ErrNewerSchema = errors.New("tracking database schema is newer than this snip")
+
+// schemaMigration is one forward step of the schema; its version is its
+// position in migrations plus one.
+type schemaMigration struct {
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// LatestVersion returns the schema version this binary writes.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// migrate brings db up to LatestVersion, one migration per transaction that
+// also records it in schema_version. When two snip processes race to upgrade
+// the same database, the loser's step fails on the version it lost; it then
+// finds the schema already past that step and carries on. A database already
+// past LatestVersion is left alone and reported with ErrNewerSchema.
+func migrate(db *sql.DB) (from, to int, err error) {
+	if _, err := db.Exec(createSchemaVersionSQL); err != nil {
+		return 0, 0, fmt.Errorf("create schema_version: %w", err)
+	}
+	from, err = schemaVersion(db)
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > len(migrations) {
+		return from, from, fmt.Errorf("version %d, this snip knows %d: %w", from, len(migrations), ErrNewerSchema)
+	}
+	for v := from; v < len(migrations); v++ {
+		if err := migrateStep(db, v); err != nil {
+			if now, verr := schemaVersion(db); verr == nil && now > v {
+				v = now - 1
+				continue
+			}
+			return from, v, fmt.Errorf("version %d: %w", v+1, err)
+		}
+	}
+	return from, len(migrations), nil
+}
+
+// migrateStep applies migrations[v] and records version v+1.
+func migrateStep(db *sql.DB, v int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	m := migrations[v]
+	if err := m.up(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description) VALUES (?, ?)`, v+1, m.description); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func schemaVersion(db *sql.DB) (int, error) {
+	var v int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+	return v, err
+}
+
+// Migrate opens the database at dbPath, creating it if needed, and brings
+// it up to LatestVersion. It returns the versions before and after; a
+// database newer than this binary is left untouched, with ErrNewerSchema.
+func Migrate(dbPath string) (from, to int, err error) {
+	db, from, to, err := openDB(dbPath)
+	if db != nil {
+		_ = db.Close()
+	}
+	return from, to, err
+}
+
+// Migration is one schema migration as recorded in a database. AppliedAt is
+// empty for a migration the database has not had yet.
+type Migration struct {
+	Version     int
+	Description string
+	AppliedAt   string
+}
+
+// DBInfo describes a tracking database.
+type DBInfo struct {
+	Path      string
+	SizeBytes int64
+	// Version is the schema version of the database and Latest the one this
+	// binary writes. Version > Latest means a newer snip migrated it, and
+	// this one only reads it.
+	Version int
+	Latest  int
+	Applied []Migration
+	Pending []Migration
+	// Commands and Unfiltered count the tracked rows; Oldest and Newest are
+	// the first and last command timestamps, empty when there are none.
+	Commands   int
+	Unfiltered int
+	Oldest     string
+	Newest     string
+	// MachineID identifies this database's own rows in exports, and
+	// Imported counts the commands imported from other machines.
+	MachineID string
+	Imported  int
+}
+
+// Inspect describes the database at dbPath without creating or migrating
+// it. A missing database is an error wrapping os.ErrNotExist.
+func Inspect(dbPath string) (*DBInfo, error) {
+	st, err := os.Stat(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+	// One connection, so query_only holds for every statement below.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("PRAGMA query_only=1"); err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
+
+	info := &DBInfo{Path: dbPath, SizeBytes: st.Size(), Latest: len(migrations)}
+
+	var applied []Migration
+	if ok, err := hasTable(db, "schema_version"); err != nil {
+		return nil, err
+	} else if ok {
+		rows, err := db.Query(`SELECT version, description, COALESCE(applied_at, '') FROM schema_version ORDER BY version`)
+		if err != nil {
+			return nil, fmt.Errorf("schema_version: %w", err)
+		}
+		for rows.Next() {
+			var m Migration
+			if err := rows.Scan(&m.Version, &m.Description, &m.AppliedAt); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("schema_version scan: %w", err)
+			}
+			applied = append(applied, m)
+			info.Version = max(info.Version, m.Version)
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("schema_version: %w", err)
+		}
+	}
+	info.Applied = applied
+	var pending []Migration
+	for v := info.Version; v < len(migrations); v++ {
+		pending = append(pending, Migration{Version: v + 1, Description: migrations[v].description})
+	}
+	info.Pending = pending
+
+	if ok, err := hasTable(db, "commands"); err != nil {
+		return nil, err
+	} else if ok {
+		err := db.QueryRow(`SELECT COUNT(*), COALESCE(MIN(timestamp), ''), COALESCE(MAX(timestamp), '') FROM commands`).
+			Scan(&info.Commands, &info.Oldest, &info.Newest)
+		if err != nil {
+			return nil, fmt.Errorf("commands: %w", err)
+		}
+	}
+	if ok, err := hasTable(db, "unfiltered_commands"); err != nil {
+		return nil, err
+	} else if ok {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM unfiltered_commands`).Scan(&info.Unfiltered); err != nil {
+			return nil, fmt.Errorf("unfiltered_commands: %w", err)
+		}
+	}
+	if ok, err := hasTable(db, "imported_rows"); err != nil {
+		return nil, err
+	} else if ok {
+		info.MachineID = loadMachineID(db)
+		err := db.QueryRow(`SELECT COUNT(*) FROM commands WHERE machine_id != ?`, info.MachineID).Scan(&info.Imported)
+		if err != nil {
+			return nil, fmt.Errorf("commands: %w", err)
+		}
+	}
+	return info, nil
+}
+
+func hasTable(db *sql.DB, name string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("inspect: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,105 @@
+package tracking
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// promCounters are the per-filter counters, in export order: metric name,
+// help text and counters column.
+var promCounters = []struct{ name, help, column string }{
+	{"snip_commands_total", "Commands run through a snip filter.", "commands"},
+	{"snip_input_tokens_total", "Tokens in the raw output of filtered commands.", "input_tokens"},
+	{"snip_output_tokens_total", "Tokens in the output snip sent on.", "output_tokens"},
+	{"snip_raw_fallbacks_total", "Filtered commands whose raw output was sent because the filter failed or emptied it.", "raw_fallbacks"},
+	{"snip_filter_errors_total", "Filtered commands whose filter failed.", "filter_errors"},
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// WritePrometheus writes the lifetime counters in the Prometheus text
+// format, labelled by filter and base command. Unlike the commands table
+// they never shrink, so rate() and increase() work across cleanups.
+func (t *Tracker) WritePrometheus(w io.Writer) error {
+	if err := t.ensureOpen(); err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	bw := bufio.NewWriter(w)
+	for _, c := range promCounters {
+		// c.column comes from promCounters, never from the caller.
+		rows, err := t.db.Query(fmt.Sprintf(`SELECT filter, command, %s FROM counters ORDER BY filter, command`, c.column))
+		if err != nil {
+			return fmt.Errorf("prometheus: %w", err)
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for rows.Next() {
+			var filterName, command string
+			var n int64
+			if err := rows.Scan(&filterName, &command, &n); err != nil {
+				_ = rows.Close()
+				return fmt.Errorf("prometheus scan: %w", err)
+			}
+			fmt.Fprintf(bw, "%s{filter=\"%s\",command=\"%s\"} %d\n", c.name, promLabelEscaper.Replace(filterName), promLabelEscaper.Replace(command), n)
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("prometheus: %w", err)
+		}
+	}
+
+	rows, err := t.db.Query(`SELECT command, commands FROM unfiltered_counters ORDER BY command`)
+	if err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	fmt.Fprint(bw, "# HELP snip_unfiltered_commands_total Commands run with no matching filter (tracking.track_unfiltered).\n# TYPE snip_unfiltered_commands_total counter\n")
+	for rows.Next() {
+		var command string
+		var n int64
+		if err := rows.Scan(&command, &n); err != nil {
+			return fmt.Errorf("prometheus scan: %w", err)
+		}
+		fmt.Fprintf(bw, "snip_unfiltered_commands_total{command=\"%s\"} %d\n", promLabelEscaper.Replace(command), n)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	return bw.Flush()
+}
+
+// WritePrometheusFile writes the counters to path atomically, through a
+// temporary file renamed over it, so node_exporter never reads half a file.
+func (t *Tracker) WritePrometheusFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snip-*.prom.tmp")
+	if err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	err = t.WritePrometheus(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600; the collector may run as another user.
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("prometheus: %w", err)
+	}
+	return nil
+}
+
+// exportPrometheus refreshes the textfile, if one is set. Best-effort, like
+// cleanup: a tracked command never fails over its metrics.
+func (t *Tracker) exportPrometheus() {
+	if t.textfile != "" {
+		_ = t.WritePrometheusFile(t.textfile)
+	}
+}
@@ -1,5 +1,13 @@
 package tracking
 
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// createTableSQL is the version 0 commands table; migrations bring it, and
+// any older database, up to the current schema.
 const createTableSQL = `
 CREATE TABLE IF NOT EXISTS commands (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -14,7 +22,196 @@ CREATE TABLE IF NOT EXISTS commands (
 );
 `
 
-const cleanupSQL = `DELETE FROM commands WHERE timestamp < datetime('now', '-90 days');`
+// createSchemaVersionSQL records each migration applied to the database.
+const createSchemaVersionSQL = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at DATETIME DEFAULT (datetime('now'))
+);
+`
+
+// migrations[i] upgrades a database at schema version i to version i+1;
+// migrate.go runs them. Never edit or reorder an entry once released: append
+// a new one.
+var migrations = []schemaMigration{
+	{"add commands.redacted", addColumns("commands", "redacted INTEGER NOT NULL DEFAULT 0")},
+	// Earlier rows all used the chars4 heuristic.
+	{"add commands.estimator", addColumns("commands", "estimator TEXT NOT NULL DEFAULT 'chars4'")},
+	// exit_code is NULL for rows recorded before it was.
+	{"add filter, exit code, project, agent and session to commands", addColumns("commands",
+		"filter TEXT NOT NULL DEFAULT ''",
+		"filter_version INTEGER NOT NULL DEFAULT 0",
+		"exit_code INTEGER",
+		"cwd TEXT NOT NULL DEFAULT ''",
+		"project_root TEXT NOT NULL DEFAULT ''",
+		"agent TEXT NOT NULL DEFAULT ''",
+		"session_id TEXT NOT NULL DEFAULT ''",
+		"raw_fallback INTEGER NOT NULL DEFAULT 0",
+	)},
+	{"add daily_rollups and tracking_state", execSQL(createRollupsSQL)},
+	{"add commands.filter_error and lifetime counters", func(tx *sql.Tx) error {
+		if err := addColumns("commands", "filter_error INTEGER NOT NULL DEFAULT 0")(tx); err != nil {
+			return err
+		}
+		return execSQL(createCountersSQL + seedCountersSQL)(tx)
+	}},
+	{"add machine and row ids for export and import", addMachineIDs},
+}
+
+// execSQL returns a migration step running stmts.
+func execSQL(stmts string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmts)
+		return err
+	}
+}
+
+// addColumns returns a migration step adding columns, each given as "name
+// type ...", to table. A column that already exists is skipped: columns were
+// added by probing for them before the schema was versioned.
+func addColumns(table string, columns ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+		if err != nil {
+			return err
+		}
+		have := make(map[string]bool)
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			have[name] = true
+		}
+		_ = rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, def := range columns {
+			name, _, _ := strings.Cut(def, " ")
+			if have[name] {
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, def)); err != nil {
+				return fmt.Errorf("add column %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// daily_rollups keeps the totals of commands past their retention, one row
+// per UTC day and filter, so daily, weekly and monthly reports reach further
+// back than the commands themselves. tracking_state holds the time of the
+// last cleanup.
+const createRollupsSQL = `
+CREATE TABLE IF NOT EXISTS daily_rollups (
+	day TEXT NOT NULL,
+	filter TEXT NOT NULL,
+	commands INTEGER NOT NULL,
+	input_tokens INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	saved_tokens INTEGER NOT NULL,
+	exec_time_ms INTEGER NOT NULL,
+	redacted INTEGER NOT NULL,
+	raw_fallbacks INTEGER NOT NULL,
+	PRIMARY KEY (day, filter)
+);
+CREATE TABLE IF NOT EXISTS tracking_state (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// claimCleanupSQL stamps the cleanup time unless a cleanup already ran in
+// the last day; it changes a row only for the process that should clean up.
+const claimCleanupSQL = `
+INSERT INTO tracking_state (key, value) VALUES ('last_cleanup', datetime('now'))
+ON CONFLICT(key) DO UPDATE SET value = excluded.value
+WHERE value < datetime('now', '-1 day');
+`
+
+// rollupSQL folds the commands of every whole day before the cutoff into
+// daily_rollups, adding to a day already there (rows imported late).
+const rollupSQL = `
+INSERT INTO daily_rollups (day, filter, commands, input_tokens, output_tokens, saved_tokens, exec_time_ms, redacted, raw_fallbacks)
+SELECT date(timestamp), filter, COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(saved_tokens), SUM(exec_time_ms), SUM(redacted), SUM(raw_fallback)
+FROM commands
+WHERE date(timestamp) < date('now', ? || ' days')
+GROUP BY date(timestamp), filter
+ON CONFLICT(day, filter) DO UPDATE SET
+	commands = commands + excluded.commands,
+	input_tokens = input_tokens + excluded.input_tokens,
+	output_tokens = output_tokens + excluded.output_tokens,
+	saved_tokens = saved_tokens + excluded.saved_tokens,
+	exec_time_ms = exec_time_ms + excluded.exec_time_ms,
+	redacted = redacted + excluded.redacted,
+	raw_fallbacks = raw_fallbacks + excluded.raw_fallbacks;
+`
+
+// counters and unfiltered_counters hold lifetime totals that cleanup never
+// touches, so the Prometheus export only ever counts up. command is the base
+// command (the first word), to keep the label set small.
+const createCountersSQL = `
+CREATE TABLE IF NOT EXISTS counters (
+	filter TEXT NOT NULL,
+	command TEXT NOT NULL,
+	commands INTEGER NOT NULL,
+	input_tokens INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	raw_fallbacks INTEGER NOT NULL,
+	filter_errors INTEGER NOT NULL,
+	PRIMARY KEY (filter, command)
+);
+CREATE TABLE IF NOT EXISTS unfiltered_counters (
+	command TEXT PRIMARY KEY,
+	commands INTEGER NOT NULL
+);
+`
+
+// seedCountersSQL starts the counters from the history already tracked;
+// rolled-up days no longer know their command.
+const seedCountersSQL = `
+INSERT INTO counters (filter, command, commands, input_tokens, output_tokens, raw_fallbacks, filter_errors)
+SELECT filter, CASE WHEN instr(original_cmd, ' ') > 0 THEN substr(original_cmd, 1, instr(original_cmd, ' ') - 1) ELSE original_cmd END,
+	COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(raw_fallback), 0
+FROM commands
+GROUP BY 1, 2;
+INSERT INTO counters (filter, command, commands, input_tokens, output_tokens, raw_fallbacks, filter_errors)
+SELECT filter, '', SUM(commands), SUM(input_tokens), SUM(output_tokens), SUM(raw_fallbacks), 0
+FROM daily_rollups
+WHERE true
+GROUP BY filter
+ON CONFLICT(filter, command) DO UPDATE SET
+	commands = commands + excluded.commands,
+	input_tokens = input_tokens + excluded.input_tokens,
+	output_tokens = output_tokens + excluded.output_tokens,
+	raw_fallbacks = raw_fallbacks + excluded.raw_fallbacks;
+INSERT INTO unfiltered_counters (command, commands)
+SELECT command, COUNT(*) FROM unfiltered_commands GROUP BY command;
+`
+
+const countSQL = `
+INSERT INTO counters (filter, command, commands, input_tokens, output_tokens, raw_fallbacks, filter_errors)
+VALUES (?, ?, 1, ?, ?, ?, ?)
+ON CONFLICT(filter, command) DO UPDATE SET
+	commands = commands + 1,
+	input_tokens = input_tokens + excluded.input_tokens,
+	output_tokens = output_tokens + excluded.output_tokens,
+	raw_fallbacks = raw_fallbacks + excluded.raw_fallbacks,
+	filter_errors = filter_errors + excluded.filter_errors;
+`
+
+const countUnfilteredSQL = `
+INSERT INTO unfiltered_counters (command, commands) VALUES (?, 1)
+ON CONFLICT(command) DO UPDATE SET commands = commands + 1;
+`
+
+const cleanupSQL = `DELETE FROM commands WHERE date(timestamp) < date('now', ? || ' days');`
+
+const cleanupRollupsSQL = `DELETE FROM daily_rollups WHERE day < date('now', ? || ' days');`
 
 // unfiltered_commands records commands that ran with no matching filter at all,
 // used to surface filter-coverage gaps (issue #96). Only the command name and
@@ -34,7 +231,7 @@ INSERT INTO unfiltered_commands (command, full_cmd)
 VALUES (?, ?);
 `
 
-const cleanupUnfilteredSQL = `DELETE FROM unfiltered_commands WHERE timestamp < datetime('now', '-14 days');`
+const cleanupUnfilteredSQL = `DELETE FROM unfiltered_commands WHERE timestamp < datetime('now', ? || ' days');`
 
 const byUnfilteredSQL = `
 SELECT
@@ -48,8 +245,16 @@ LIMIT ?;
 `
 
 const insertSQL = `
-INSERT INTO commands (original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms)
-VALUES (?, ?, ?, ?, ?, ?, ?);
+INSERT INTO commands (original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms, redacted, estimator,
+	filter, filter_version, exit_code, cwd, project_root, agent, session_id, raw_fallback, filter_error, uuid, machine_id)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const estimatorsSQL = `
+SELECT estimator, COUNT(*)
+FROM commands
+GROUP BY estimator
+ORDER BY COUNT(*) DESC, estimator;
 `
 
 const summarySQL = `
@@ -57,21 +262,37 @@ SELECT
 	COUNT(*) as total_commands,
 	COALESCE(SUM(saved_tokens), 0) as total_saved,
 	COALESCE(SUM(saved_tokens) * 100.0 / NULLIF(SUM(input_tokens), 0), 0) as avg_savings,
-	COALESCE(SUM(exec_time_ms), 0) as total_time_ms
+	COALESCE(SUM(exec_time_ms), 0) as total_time_ms,
+	COALESCE(SUM(redacted), 0) as total_redacted
 FROM commands;
 `
 
+// dayTotalsSQL yields per-day totals over the last N days (both parameters
+// are -N) for the reports to sum up: recent days come from commands, older
+// ones from the rollups their commands were folded into. A day is never in both, since
+// cleanup folds and deletes whole days.
+const dayTotalsSQL = `
+SELECT date(timestamp) as day, COUNT(*) as commands, SUM(input_tokens) as input_tokens,
+	SUM(output_tokens) as output_tokens, SUM(saved_tokens) as saved_tokens
+FROM commands
+WHERE timestamp >= datetime('now', ? || ' days')
+GROUP BY date(timestamp)
+UNION ALL
+SELECT day, commands, input_tokens, output_tokens, saved_tokens
+FROM daily_rollups
+WHERE day >= date('now', ? || ' days')
+`
+
 const dailySQL = `
 SELECT
-	date(timestamp) as day,
-	COUNT(*) as commands,
+	day,
+	SUM(commands) as commands,
 	SUM(input_tokens) as input_tokens,
 	SUM(output_tokens) as output_tokens,
 	SUM(saved_tokens) as saved_tokens,
 	COALESCE(SUM(saved_tokens) * 100.0 / NULLIF(SUM(input_tokens), 0), 0) as avg_savings
-FROM commands
-WHERE timestamp >= datetime('now', ? || ' days')
-GROUP BY date(timestamp)
+FROM (` + dayTotalsSQL + `)
+GROUP BY day
 ORDER BY day DESC;
 `
 
@@ -98,29 +319,27 @@ LIMIT ?;
 
 const weeklySQL = `
 SELECT
-	strftime('%Y-W%W', timestamp) as period,
-	COUNT(*) as commands,
+	strftime('%Y-W%W', day) as period,
+	SUM(commands) as commands,
 	SUM(input_tokens) as input_tokens,
 	SUM(output_tokens) as output_tokens,
 	SUM(saved_tokens) as saved_tokens,
 	COALESCE(SUM(saved_tokens) * 100.0 / NULLIF(SUM(input_tokens), 0), 0) as avg_savings
-FROM commands
-WHERE timestamp >= datetime('now', ? || ' days')
-GROUP BY strftime('%Y-W%W', timestamp)
+FROM (` + dayTotalsSQL + `)
+GROUP BY strftime('%Y-W%W', day)
 ORDER BY period DESC;
 `
 
 const monthlySQL = `
 SELECT
-	strftime('%Y-%m', timestamp) as period,
-	COUNT(*) as commands,
+	strftime('%Y-%m', day) as period,
+	SUM(commands) as commands,
 	SUM(input_tokens) as input_tokens,
 	SUM(output_tokens) as output_tokens,
 	SUM(saved_tokens) as saved_tokens,
 	COALESCE(SUM(saved_tokens) * 100.0 / NULLIF(SUM(input_tokens), 0), 0) as avg_savings
-FROM commands
-WHERE timestamp >= datetime('now', ? || ' days')
-GROUP BY strftime('%Y-%m', timestamp)
+FROM (` + dayTotalsSQL + `)
+GROUP BY strftime('%Y-%m', day)
 ORDER BY period DESC;
 `
 
@@ -130,6 +349,11 @@ type Summary struct {
 	TotalSaved    int
 	AvgSavings    float64
 	TotalTimeMs   int64
+	TotalRedacted int
+	// Estimators counts commands by the token estimator that measured them,
+	// so savings measured by different estimators are not mistaken for a
+	// trend.
+	Estimators map[string]int
 }
 
 // DayStats holds daily tracking stats.
@@ -25,6 +25,15 @@ func (te *TimedExecution) Track(originalCmd, snipCmd string, inputTokens, output
 	return te.tracker.Track(originalCmd, snipCmd, inputTokens, outputTokens, ms)
 }
 
+// TrackRecord is Tracker.TrackRecord with the elapsed duration filled in.
+func (te *TimedExecution) TrackRecord(r Record) error {
+	if te.tracker == nil {
+		return nil
+	}
+	r.ExecTimeMs = time.Since(te.startTime).Milliseconds()
+	return te.tracker.TrackRecord(r)
+}
+
 // TrackPassthrough records a passthrough command with elapsed duration.
 func (te *TimedExecution) TrackPassthrough(cmd string, tokens int) error {
 	if te.tracker == nil {
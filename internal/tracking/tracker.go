@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+
+	"github.com/edouard-claude/snip/internal/tokens"
 )
 
 // ErrUnavailable reports that token tracking is unavailable because the SQLite
@@ -22,11 +25,34 @@ type Tracker struct {
 	dbPath  string
 	once    sync.Once
 	initErr error
+	// readOnly is set when the database schema is newer than this binary.
+	readOnly  bool
+	retention Retention
+	// textfile, when set, receives the Prometheus counters after each
+	// tracked command.
+	textfile string
+	// machineID identifies this machine's rows in exports.
+	machineID string
+}
+
+// Retention says how many days tracked data is kept; 0 keeps it forever.
+// Commands past their retention are folded into daily rollups, which the
+// daily, weekly and monthly reports read, before they are deleted.
+type Retention struct {
+	Commands   int
+	Unfiltered int
+	Rollups    int
+}
+
+// DefaultRetention keeps commands 90 days, unfiltered commands 14 days and
+// rollups forever.
+func DefaultRetention() Retention {
+	return Retention{Commands: 90, Unfiltered: 14}
 }
 
 // NewTracker opens or creates a SQLite database for tracking (immediate open).
 func NewTracker(dbPath string) (*Tracker, error) {
-	t := &Tracker{dbPath: dbPath}
+	t := &Tracker{dbPath: dbPath, retention: DefaultRetention()}
 	if err := t.ensureOpen(); err != nil {
 		return nil, err
 	}
@@ -35,7 +61,19 @@ func NewTracker(dbPath string) (*Tracker, error) {
 
 // NewLazyTracker creates a tracker that defers DB opening until first use.
 func NewLazyTracker(dbPath string) *Tracker {
-	return &Tracker{dbPath: dbPath}
+	return &Tracker{dbPath: dbPath, retention: DefaultRetention()}
+}
+
+// SetPrometheusTextfile makes the tracker rewrite path with the Prometheus
+// counters (see WritePrometheus) after each tracked command, for
+// node_exporter's textfile collector. Empty turns it off.
+func (t *Tracker) SetPrometheusTextfile(path string) {
+	t.textfile = path
+}
+
+// SetRetention replaces DefaultRetention. Call it before tracking.
+func (t *Tracker) SetRetention(r Retention) {
+	t.retention = r
 }
 
 // WarmUp starts opening the DB in the background.
@@ -46,60 +84,171 @@ func (t *Tracker) WarmUp() {
 
 func (t *Tracker) ensureOpen() error {
 	t.once.Do(func() {
-		dir := filepath.Dir(t.dbPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			t.initErr = fmt.Errorf("%w: create db dir: %v", ErrUnavailable, err)
+		db, _, _, err := openDB(t.dbPath)
+		// A database written by a newer snip stays readable, so gain still
+		// works, but this binary must not write rows in a schema it does
+		// not know.
+		if errors.Is(err, ErrNewerSchema) {
+			t.readOnly = true
+		} else if err != nil {
+			t.initErr = err
 			return
 		}
+		t.db = db
+		t.machineID = loadMachineID(db)
+	})
+	return t.initErr
+}
 
-		db, err := sql.Open("sqlite", t.dbPath)
-		if err != nil {
-			t.initErr = fmt.Errorf("%w: open db: %v", ErrUnavailable, err)
-			return
-		}
+// openDB opens or creates the database at dbPath and migrates it, returning
+// the schema versions before and after. When the database is newer than
+// this binary it is returned open, with ErrNewerSchema.
+func openDB(dbPath string) (db *sql.DB, from, to int, err error) {
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: create db dir: %v", ErrUnavailable, err)
+	}
 
-		_, _ = db.Exec("PRAGMA journal_mode=WAL")
-		_, _ = db.Exec("PRAGMA busy_timeout=5000")
+	db, err = sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("%w: open db: %v", ErrUnavailable, err)
+	}
 
-		if _, err := db.Exec(createTableSQL); err != nil {
-			_ = db.Close()
-			t.initErr = fmt.Errorf("%w: create table: %v", ErrUnavailable, err)
-			return
-		}
+	_, _ = db.Exec("PRAGMA journal_mode=WAL")
+	_, _ = db.Exec("PRAGMA busy_timeout=5000")
 
-		if _, err := db.Exec(createUnfilteredTableSQL); err != nil {
-			_ = db.Close()
-			t.initErr = fmt.Errorf("create unfiltered table: %w", err)
-			return
-		}
+	if _, err := db.Exec(createTableSQL); err != nil {
+		_ = db.Close()
+		return nil, 0, 0, fmt.Errorf("%w: create table: %v", ErrUnavailable, err)
+	}
 
-		t.db = db
-	})
-	return t.initErr
+	if _, err := db.Exec(createUnfilteredTableSQL); err != nil {
+		_ = db.Close()
+		return nil, 0, 0, fmt.Errorf("create unfiltered table: %w", err)
+	}
+
+	from, to, err = migrate(db)
+	if errors.Is(err, ErrNewerSchema) {
+		return db, from, to, err
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, from, to, fmt.Errorf("migrate: %w", err)
+	}
+	return db, from, to, nil
+}
+
+// writable is ensureOpen for the methods that insert rows.
+func (t *Tracker) writable() error {
+	if err := t.ensureOpen(); err != nil {
+		return err
+	}
+	if t.readOnly {
+		return fmt.Errorf("%w: %w", ErrUnavailable, ErrNewerSchema)
+	}
+	return nil
+}
+
+// Record is one tracked command execution.
+type Record struct {
+	OriginalCmd  string
+	SnipCmd      string
+	InputTokens  int
+	OutputTokens int
+	ExecTimeMs   int64
+	// Redacted is the number of secrets redacted from the output.
+	Redacted int
+	// Estimator names the token estimator that produced InputTokens and
+	// OutputTokens; empty means the current default.
+	Estimator string
+
+	// Filter and FilterVersion identify the filter that handled the command.
+	Filter        string
+	FilterVersion int
+	ExitCode      int
+	// Cwd is the directory the command ran in; empty means the current
+	// one. ProjectRoot is the enclosing repository, found from Cwd when empty.
+	Cwd         string
+	ProjectRoot string
+	// Agent and SessionID say who ran the command; empty means DetectAgent
+	// and SessionID.
+	Agent     string
+	SessionID string
+	// RawFallback reports that the raw output was sent instead of the
+	// filtered one, because the filter failed or emptied it. FilterError
+	// reports that it failed.
+	RawFallback bool
+	FilterError bool
 }
 
 // Track records a filtered command execution.
 func (t *Tracker) Track(originalCmd, snipCmd string, inputTokens, outputTokens int, execTimeMs int64) error {
-	if err := t.ensureOpen(); err != nil {
+	return t.TrackRecord(Record{
+		OriginalCmd:  originalCmd,
+		SnipCmd:      snipCmd,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		ExecTimeMs:   execTimeMs,
+	})
+}
+
+// TrackRecord records r.
+func (t *Tracker) TrackRecord(r Record) error {
+	if err := t.writable(); err != nil {
 		return fmt.Errorf("track: %w", err)
 	}
 
-	saved := inputTokens - outputTokens
+	saved := r.InputTokens - r.OutputTokens
 	pct := 0.0
-	if inputTokens > 0 {
-		pct = float64(saved) / float64(inputTokens) * 100
+	if r.InputTokens > 0 {
+		pct = float64(saved) / float64(r.InputTokens) * 100
 	}
+	if r.Estimator == "" {
+		r.Estimator = tokens.Default().Name()
+	}
+	r.fillContext()
 
-	if _, err := t.db.Exec(insertSQL, originalCmd, snipCmd, inputTokens, outputTokens, saved, pct, execTimeMs); err != nil {
+	err := t.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(insertSQL, r.OriginalCmd, r.SnipCmd, r.InputTokens, r.OutputTokens, saved, pct, r.ExecTimeMs, r.Redacted, r.Estimator,
+			r.Filter, r.FilterVersion, r.ExitCode, r.Cwd, r.ProjectRoot, r.Agent, r.SessionID, r.RawFallback, r.FilterError,
+			newUUID(), t.machineID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(countSQL, r.Filter, baseCommand(r.OriginalCmd), r.InputTokens, r.OutputTokens, r.RawFallback, r.FilterError)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("track: %w", err)
 	}
 
 	// Cleanup old records (best-effort)
-	_, _ = t.db.Exec(cleanupSQL)
+	_ = t.cleanup()
+	t.exportPrometheus()
 
 	return nil
 }
 
+// inTx runs fn in a transaction, committed when fn succeeds.
+func (t *Tracker) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// baseCommand is the first word of a command line, the command label of
+// the lifetime counters.
+func baseCommand(cmdline string) string {
+	cmd, _, _ := strings.Cut(strings.TrimSpace(cmdline), " ")
+	return cmd
+}
+
 // TrackPassthrough records a passthrough (unfiltered) command.
 func (t *Tracker) TrackPassthrough(cmd string, tokens int, execTimeMs int64) error {
 	return t.Track(cmd, cmd, tokens, tokens, execTimeMs)
@@ -109,16 +258,65 @@ func (t *Tracker) TrackPassthrough(cmd string, tokens int, execTimeMs int64) err
 // coverage gaps can be surfaced later (issue #96). command is the base command;
 // fullCmd is the full invocation. Best-effort: callers should ignore the error.
 func (t *Tracker) TrackUnfiltered(command, fullCmd string) error {
-	if err := t.ensureOpen(); err != nil {
+	if err := t.writable(); err != nil {
 		return fmt.Errorf("track unfiltered: %w", err)
 	}
-	if _, err := t.db.Exec(insertUnfilteredSQL, command, fullCmd); err != nil {
+	err := t.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(insertUnfilteredSQL, command, fullCmd); err != nil {
+			return err
+		}
+		_, err := tx.Exec(countUnfilteredSQL, command)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("track unfiltered: %w", err)
 	}
-	_, _ = t.db.Exec(cleanupUnfilteredSQL)
+	_ = t.cleanup()
+	t.exportPrometheus()
 	return nil
 }
 
+// cleanup expires rows past their retention, at most once a day across all
+// snip processes: the one that claims the day does it, in one transaction,
+// so a day's commands are never both folded into the rollups and still
+// counted from the commands table.
+func (t *Tracker) cleanup() error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cleanup: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(claimCleanupSQL)
+	if err != nil {
+		return fmt.Errorf("cleanup: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	r := t.retention
+	steps := []struct {
+		days  int
+		stmts []string
+	}{
+		{r.Commands, []string{rollupSQL, cleanupSQL}},
+		{r.Unfiltered, []string{cleanupUnfilteredSQL}},
+		{r.Rollups, []string{cleanupRollupsSQL}},
+	}
+	for _, step := range steps {
+		if step.days <= 0 {
+			continue
+		}
+		for _, stmt := range step.stmts {
+			if _, err := tx.Exec(stmt, fmt.Sprintf("-%d", step.days)); err != nil {
+				return fmt.Errorf("cleanup: %w", err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
 // GetUnfiltered returns the most frequent unfiltered commands.
 func (t *Tracker) GetUnfiltered(limit int) ([]UnfilteredStat, error) {
 	if err := t.ensureOpen(); err != nil {
@@ -150,10 +348,28 @@ func (t *Tracker) GetSummary() (*Summary, error) {
 		return nil, fmt.Errorf("summary: %w", err)
 	}
 	var s Summary
-	err := t.db.QueryRow(summarySQL).Scan(&s.TotalCommands, &s.TotalSaved, &s.AvgSavings, &s.TotalTimeMs)
+	err := t.db.QueryRow(summarySQL).Scan(&s.TotalCommands, &s.TotalSaved, &s.AvgSavings, &s.TotalTimeMs, &s.TotalRedacted)
 	if err != nil {
 		return nil, fmt.Errorf("summary: %w", err)
 	}
+
+	rows, err := t.db.Query(estimatorsSQL)
+	if err != nil {
+		return nil, fmt.Errorf("summary estimators: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	s.Estimators = make(map[string]int)
+	for rows.Next() {
+		var name string
+		var n int
+		if err := rows.Scan(&name, &n); err != nil {
+			return nil, fmt.Errorf("summary estimators: %w", err)
+		}
+		s.Estimators[name] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("summary estimators: %w", err)
+	}
 	return &s, nil
 }
 
@@ -165,7 +381,8 @@ func (t *Tracker) GetDaily(days int) ([]DayStats, error) {
 	if days <= 0 {
 		days = 7
 	}
-	rows, err := t.db.Query(dailySQL, fmt.Sprintf("-%d", days))
+	since := fmt.Sprintf("-%d", days)
+	rows, err := t.db.Query(dailySQL, since, since)
 	if err != nil {
 		return nil, fmt.Errorf("daily: %w", err)
 	}
@@ -238,7 +455,8 @@ func (t *Tracker) GetWeekly(weeks int) ([]PeriodStats, error) {
 		weeks = 4
 	}
 	days := weeks * 7
-	rows, err := t.db.Query(weeklySQL, fmt.Sprintf("-%d", days))
+	since := fmt.Sprintf("-%d", days)
+	rows, err := t.db.Query(weeklySQL, since, since)
 	if err != nil {
 		return nil, fmt.Errorf("weekly: %w", err)
 	}
@@ -264,7 +482,8 @@ func (t *Tracker) GetMonthly(months int) ([]PeriodStats, error) {
 		months = 6
 	}
 	days := months * 30
-	rows, err := t.db.Query(monthlySQL, fmt.Sprintf("-%d", days))
+	since := fmt.Sprintf("-%d", days)
+	rows, err := t.db.Query(monthlySQL, since, since)
 	if err != nil {
 		return nil, fmt.Errorf("monthly: %w", err)
 	}
@@ -3,10 +3,17 @@
 package tracking
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestTrackUnwritableDBIsUnavailable verifies that when the tracking DB path is
@@ -117,6 +124,244 @@ func TestTrack(t *testing.T) {
 	}
 }
 
+func TestTrackRedacted(t *testing.T) {
+	tracker := newTestTracker(t)
+	if err := tracker.TrackRecord(Record{OriginalCmd: "env", SnipCmd: "snip env", InputTokens: 100, OutputTokens: 50, ExecTimeMs: 5, Redacted: 3}); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	if err := tracker.Track("git log", "snip git log", 100, 50, 5); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	summary, err := tracker.GetSummary()
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.TotalRedacted != 3 {
+		t.Errorf("total redacted = %d, want 3", summary.TotalRedacted)
+	}
+}
+
+func TestTrackRecordEstimator(t *testing.T) {
+	tracker := newTestTracker(t)
+	if err := tracker.Track("ls", "snip ls", 100, 50, 5); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		err := tracker.TrackRecord(Record{OriginalCmd: "ls", SnipCmd: "snip ls", InputTokens: 90, OutputTokens: 40, Estimator: "bpe:test"})
+		if err != nil {
+			t.Fatalf("track record: %v", err)
+		}
+	}
+	summary, err := tracker.GetSummary()
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	want := map[string]int{"chars4": 1, "bpe:test": 2}
+	if !reflect.DeepEqual(summary.Estimators, want) {
+		t.Errorf("estimators = %v, want %v", summary.Estimators, want)
+	}
+}
+
+// TestTrackUpgradesOldSchema verifies that a database created before the
+// redacted and estimator columns existed gains them on open and keeps its
+// rows, which are attributed to the heuristic.
+func TestTrackUpgradesOldSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE commands (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME DEFAULT (datetime('now')),
+		original_cmd TEXT NOT NULL, snip_cmd TEXT NOT NULL,
+		input_tokens INTEGER NOT NULL, output_tokens INTEGER NOT NULL,
+		saved_tokens INTEGER NOT NULL, savings_pct REAL NOT NULL,
+		exec_time_ms INTEGER NOT NULL);
+		INSERT INTO commands (original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms)
+		VALUES ('ls', 'snip ls', 10, 5, 5, 50, 1);`)
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := NewTracker(dbPath)
+	if err != nil {
+		t.Fatalf("new tracker: %v", err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+	if err := tracker.TrackRecord(Record{OriginalCmd: "env", SnipCmd: "snip env", InputTokens: 10, OutputTokens: 5, ExecTimeMs: 1, Redacted: 2}); err != nil {
+		t.Fatalf("track: %v", err)
+	}
+	summary, err := tracker.GetSummary()
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.TotalCommands != 2 || summary.TotalRedacted != 2 {
+		t.Errorf("summary = %+v, want 2 commands and 2 redacted", summary)
+	}
+	if summary.Estimators["chars4"] != 2 {
+		t.Errorf("estimators = %v, want 2 chars4 rows", summary.Estimators)
+	}
+	var unknown int
+	if err := tracker.db.QueryRow(`SELECT COUNT(*) FROM commands WHERE exit_code IS NULL`).Scan(&unknown); err != nil || unknown != 1 {
+		t.Errorf("rows with unknown exit code = %d, %v; want the 1 old row", unknown, err)
+	}
+}
+
+func TestTrackRecordContext(t *testing.T) {
+	t.Setenv("SNIP_AGENT", "test-agent")
+	t.Setenv("SNIP_SESSION_ID", "s-1")
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "pkg", "x")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := newTestTracker(t)
+	err := tracker.TrackRecord(Record{
+		OriginalCmd: "go test", SnipCmd: "go test -json", InputTokens: 100, OutputTokens: 10,
+		Filter: "go-test", FilterVersion: 3, ExitCode: 1, Cwd: sub, RawFallback: true,
+	})
+	if err != nil {
+		t.Fatalf("track: %v", err)
+	}
+
+	var (
+		filterName, cwd, root, agent, session string
+		version, exitCode                     int
+		rawFallback                           bool
+	)
+	err = tracker.db.QueryRow(`SELECT filter, filter_version, exit_code, cwd, project_root, agent, session_id, raw_fallback FROM commands`).
+		Scan(&filterName, &version, &exitCode, &cwd, &root, &agent, &session, &rawFallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filterName != "go-test" || version != 3 || exitCode != 1 || cwd != sub || root != repo ||
+		agent != "test-agent" || session != "s-1" || !rawFallback {
+		t.Errorf("row = %q v%d exit %d cwd %q root %q agent %q session %q fallback %v",
+			filterName, version, exitCode, cwd, root, agent, session, rawFallback)
+	}
+}
+
+func TestDetectAgent(t *testing.T) {
+	t.Setenv("SNIP_AGENT", "")
+	t.Setenv("CLAUDECODE", "1")
+	if got := DetectAgent(); got != "claude-code" {
+		t.Errorf("marker: got %q", got)
+	}
+	t.Setenv("SNIP_AGENT", "mine")
+	if got := DetectAgent(); got != "mine" {
+		t.Errorf("SNIP_AGENT: got %q", got)
+	}
+}
+
+// TestMigrateUnversionedUpgradedSchema verifies that a database whose
+// redacted and estimator columns were added before the schema was versioned
+// (schema version 0) migrates without re-adding them.
+func TestMigrateUnversionedUpgradedSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "probed.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(createTableSQL + `
+		ALTER TABLE commands ADD COLUMN redacted INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE commands ADD COLUMN estimator TEXT NOT NULL DEFAULT 'chars4';`)
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := NewTracker(dbPath)
+	if err != nil {
+		t.Fatalf("new tracker: %v", err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+	v, err := schemaVersion(tracker.db)
+	if err != nil || v != len(migrations) {
+		t.Errorf("schema version = %d, %v; want %d", v, err, len(migrations))
+	}
+	if err := tracker.TrackRecord(Record{OriginalCmd: "ls", SnipCmd: "ls", InputTokens: 2, OutputTokens: 1}); err != nil {
+		t.Errorf("track after migrate: %v", err)
+	}
+}
+
+// TestNewerSchemaIsReadOnly verifies that a database migrated by a newer
+// snip is still read but not written.
+func TestNewerSchemaIsReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "newer.db")
+	tracker, err := NewTracker(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Track("ls", "ls", 10, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	next := LatestVersion() + 1
+	if _, err := tracker.db.Exec(`INSERT INTO schema_version (version, description) VALUES (?, 'from the future')`, next); err != nil {
+		t.Fatal(err)
+	}
+	_ = tracker.Close()
+
+	tracker, err = NewTracker(dbPath)
+	if err != nil {
+		t.Fatalf("open newer db: %v", err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+	err = tracker.Track("ls", "ls", 10, 5, 1)
+	if !errors.Is(err, ErrNewerSchema) || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("track on newer db: got %v, want ErrNewerSchema and ErrUnavailable", err)
+	}
+	s, err := tracker.GetSummary()
+	if err != nil || s.TotalCommands != 1 {
+		t.Errorf("summary on newer db = %+v, %v; want the 1 old row", s, err)
+	}
+
+	if _, _, err := Migrate(dbPath); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("migrate newer db: got %v", err)
+	}
+	info, err := Inspect(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != next || info.Latest != LatestVersion() || len(info.Pending) != 0 {
+		t.Errorf("info = version %d latest %d pending %d", info.Version, info.Latest, len(info.Pending))
+	}
+}
+
+func TestMigrateAndInspect(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "fresh.db")
+	if _, err := Inspect(dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("inspect missing db: got %v", err)
+	}
+
+	from, to, err := Migrate(dbPath)
+	if err != nil || from != 0 || to != LatestVersion() {
+		t.Fatalf("migrate = %d -> %d, %v", from, to, err)
+	}
+	from, to, err = Migrate(dbPath)
+	if err != nil || from != to {
+		t.Errorf("second migrate = %d -> %d, %v; want a no-op", from, to, err)
+	}
+
+	info, err := Inspect(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != LatestVersion() || len(info.Applied) != LatestVersion() || len(info.Pending) != 0 {
+		t.Errorf("info = version %d, %d applied, %d pending", info.Version, len(info.Applied), len(info.Pending))
+	}
+	for i, m := range info.Applied {
+		if m.Version != i+1 || m.Description != migrations[i].description || m.AppliedAt == "" {
+			t.Errorf("applied[%d] = %+v", i, m)
+		}
+	}
+}
+
 func TestTrackPassthrough(t *testing.T) {
 	tracker := newTestTracker(t)
 
@@ -268,3 +513,331 @@ func TestDBPath(t *testing.T) {
 		t.Errorf("got %q", got)
 	}
 }
+
+func TestGetBreakdown(t *testing.T) {
+	tracker := newTestTracker(t)
+	for _, r := range []Record{
+		{OriginalCmd: "go test ./a", InputTokens: 1000, OutputTokens: 100, Filter: "go-test", ProjectRoot: "/p", Agent: "cursor"},
+		{OriginalCmd: "go test ./b", InputTokens: 1000, OutputTokens: 500, Filter: "go-test", ProjectRoot: "/p", Agent: "cursor", ExitCode: 1, RawFallback: true},
+		{OriginalCmd: "git log", InputTokens: 200, OutputTokens: 100, Filter: "git-log", ProjectRoot: "/q", Agent: "claude-code"},
+	} {
+		r.SnipCmd = r.OriginalCmd
+		if err := tracker.TrackRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A row from before filters were recorded.
+	if _, err := tracker.db.Exec(`INSERT INTO commands (original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms) VALUES ('ls', 'ls', 10, 10, 0, 0, 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	byFilter, err := tracker.GetBreakdown("filter", Scope{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GroupStats{
+		{Group: "go-test", Count: 2, InputTokens: 2000, OutputTokens: 600, SavedTokens: 1400, AvgSavings: 70, RawFallbacks: 1},
+		{Group: "git-log", Count: 1, InputTokens: 200, OutputTokens: 100, SavedTokens: 100, AvgSavings: 50},
+		{Group: "(unknown)", Count: 1, InputTokens: 10, OutputTokens: 10},
+	}
+	if !reflect.DeepEqual(byFilter, want) {
+		t.Errorf("by filter:\n got %+v\nwant %+v", byFilter, want)
+	}
+
+	byStatus, err := tracker.GetBreakdown("status", Scope{ProjectRoot: "/p"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byStatus) != 2 || byStatus[0].Group != "ok" || byStatus[1].Group != "failed" {
+		t.Errorf("by status in /p: %+v", byStatus)
+	}
+
+	byAgent, err := tracker.GetBreakdown("agent", Scope{Since: "2999-01-01"}, 0)
+	if err != nil || len(byAgent) != 0 {
+		t.Errorf("future since = %+v, %v; want nothing", byAgent, err)
+	}
+
+	if _, err := tracker.GetBreakdown("color", Scope{}, 0); err == nil {
+		t.Error("unknown dimension: want error")
+	}
+	if _, err := tracker.GetBreakdown("agent", Scope{Since: "last week"}, 0); err == nil {
+		t.Error("bad since: want error")
+	}
+}
+
+// TestCleanupRollsUpExpiredDays verifies that commands past their retention
+// leave their totals in daily_rollups, where GetDaily still finds them, and
+// that cleanup runs at most once a day.
+func TestCleanupRollsUpExpiredDays(t *testing.T) {
+	tracker := newTestTracker(t)
+	tracker.SetRetention(Retention{Commands: 30, Unfiltered: 14})
+	insertOld := func(daysAgo, input, output int) {
+		t.Helper()
+		_, err := tracker.db.Exec(`INSERT INTO commands (timestamp, original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms, filter)
+			VALUES (datetime('now', ? || ' days'), 'go test', 'go test', ?, ?, ?, 0, 0, 'go-test')`, fmt.Sprintf("-%d", daysAgo), input, output, input-output)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	insertOld(100, 1000, 100)
+	insertOld(100, 500, 100)
+	insertOld(10, 300, 100)
+
+	if err := tracker.Track("ls", "ls", 10, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var raw int
+	if err := tracker.db.QueryRow(`SELECT COUNT(*) FROM commands`).Scan(&raw); err != nil || raw != 2 {
+		t.Errorf("commands left = %d, %v; want the 2 recent ones", raw, err)
+	}
+	var rolled, saved int
+	err := tracker.db.QueryRow(`SELECT commands, saved_tokens FROM daily_rollups WHERE filter = 'go-test'`).Scan(&rolled, &saved)
+	if err != nil || rolled != 2 || saved != 1300 {
+		t.Errorf("rollup = %d commands, %d saved, %v; want 2, 1300", rolled, saved, err)
+	}
+
+	daily, err := tracker.GetDaily(365)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, d := range daily {
+		total += d.Commands
+	}
+	if len(daily) < 2 || total != 4 {
+		t.Errorf("daily over a year = %+v; want the rolled-up day too, 4 commands", daily)
+	}
+
+	// Already cleaned today: an expired row stays until tomorrow.
+	insertOld(100, 10, 5)
+	if err := tracker.Track("ls", "ls", 10, 5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.db.QueryRow(`SELECT COUNT(*) FROM commands`).Scan(&raw); err != nil || raw != 4 {
+		t.Errorf("commands after second track = %d, %v; want 4 (no second cleanup)", raw, err)
+	}
+}
+
+func TestCompareFlagsRegression(t *testing.T) {
+	tracker := newTestTracker(t)
+	insert := func(daysAgo int, filter string, input, output int) {
+		t.Helper()
+		_, err := tracker.db.Exec(`INSERT INTO commands (timestamp, original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms, filter)
+			VALUES (datetime('now', ? || ' days'), ?, ?, ?, ?, ?, 0, 0, ?)`, fmt.Sprintf("-%d", daysAgo), filter, filter, input, output, input-output, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(10, "go-test", 1000, 100) // 90%
+	insert(2, "go-test", 1000, 500)  // 50%
+	insert(10, "git-log", 1000, 200) // 80%
+	insert(2, "git-log", 1000, 250)  // 75%
+	insert(2, "cargo", 100, 10)
+
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format("2006-01-02") }
+	deltas, err := tracker.Compare("filter", Scope{Since: day(-13), Until: day(-7)}, Scope{Since: day(-6), Until: day(0)}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Delta)
+	for _, d := range deltas {
+		got[d.Group] = d
+	}
+	if d := got["go-test"]; !d.Regression || d.SavingsDelta != -40 || d.SavedDelta != -400 {
+		t.Errorf("go-test = %+v; want a 40-point regression", d)
+	}
+	if d := got["git-log"]; d.Regression || d.SavingsDelta != -5 {
+		t.Errorf("git-log = %+v; want -5 points, under the threshold", d)
+	}
+	if d := got["cargo"]; d.Regression || d.Base.Count != 0 || d.Current.Count != 1 {
+		t.Errorf("cargo = %+v; want new in the current period", d)
+	}
+}
+
+// TestCompareGroupsByBaseCommand verifies that per-command deltas pair runs
+// of one command with different arguments.
+func TestCompareGroupsByBaseCommand(t *testing.T) {
+	tracker := newTestTracker(t)
+	for _, r := range []struct {
+		daysAgo int
+		cmd     string
+	}{{10, "go test ./pkg/a"}, {2, "go test ./pkg/b"}, {2, "git log -5"}} {
+		_, err := tracker.db.Exec(`INSERT INTO commands (timestamp, original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms)
+			VALUES (datetime('now', ? || ' days'), ?, ?, 100, 50, 50, 50, 0)`, fmt.Sprintf("-%d", r.daysAgo), r.cmd, r.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	day := func(offset int) string { return time.Now().AddDate(0, 0, offset).Format("2006-01-02") }
+	deltas, err := tracker.Compare("command", Scope{Since: day(-13), Until: day(-7)}, Scope{Since: day(-6), Until: day(0)}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Delta)
+	for _, d := range deltas {
+		got[d.Group] = d
+	}
+	if d, ok := got["go"]; !ok || d.Base.Count != 1 || d.Current.Count != 1 {
+		t.Errorf("go = %+v; want one run in each period", d)
+	}
+	if _, ok := got["git"]; !ok || len(got) != 2 {
+		t.Errorf("groups = %v; want go and git", got)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	tracker := newTestTracker(t)
+	records := []Record{
+		{OriginalCmd: "go test ./a", InputTokens: 1000, OutputTokens: 100, Filter: "go-test"},
+		{OriginalCmd: "go test ./b", InputTokens: 500, OutputTokens: 500, Filter: "go-test", RawFallback: true, FilterError: true},
+		{OriginalCmd: `we"ird cmd`, InputTokens: 10, OutputTokens: 5, Filter: "x"},
+	}
+	for _, r := range records {
+		r.SnipCmd = r.OriginalCmd
+		if err := tracker.TrackRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tracker.TrackUnfiltered("make", "make all"); err != nil {
+		t.Fatal(err)
+	}
+	// Cleanup deleting history must not make the counters go down.
+	if _, err := tracker.db.Exec(`DELETE FROM commands`); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snip.prom")
+	if err := tracker.WritePrometheusFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		"# TYPE snip_commands_total counter\n",
+		`snip_commands_total{filter="go-test",command="go"} 2`,
+		`snip_input_tokens_total{filter="go-test",command="go"} 1500`,
+		`snip_output_tokens_total{filter="go-test",command="go"} 600`,
+		`snip_raw_fallbacks_total{filter="go-test",command="go"} 1`,
+		`snip_filter_errors_total{filter="go-test",command="go"} 1`,
+		`snip_commands_total{filter="x",command="we\"ird"} 1`,
+		`snip_unfiltered_commands_total{command="make"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+// TestCountersSeededFromHistory verifies that upgrading a database starts
+// the lifetime counters from the commands it already tracked.
+func TestCountersSeededFromHistory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(createTableSQL + `
+		INSERT INTO commands (original_cmd, snip_cmd, input_tokens, output_tokens, saved_tokens, savings_pct, exec_time_ms)
+		VALUES ('git log -5', 'git log -5', 100, 10, 90, 90, 1), ('git status', 'git status', 50, 10, 40, 80, 1);`)
+	_ = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker, err := NewTracker(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = tracker.Close() })
+	var commands, input int
+	err = tracker.db.QueryRow(`SELECT commands, input_tokens FROM counters WHERE command = 'git'`).Scan(&commands, &input)
+	if err != nil || commands != 2 || input != 150 {
+		t.Errorf("seeded counters = %d commands, %d input, %v; want 2, 150", commands, input, err)
+	}
+}
+
+func TestExportImportMergesOnce(t *testing.T) {
+	laptop := newTestTracker(t)
+	for _, r := range []Record{
+		{OriginalCmd: "go test ./...", InputTokens: 1000, OutputTokens: 100, Filter: "go-test"},
+		{OriginalCmd: "git log", InputTokens: 400, OutputTokens: 200, Filter: "git-log", ExitCode: 1},
+	} {
+		r.SnipCmd = r.OriginalCmd
+		if err := laptop.TrackRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var export bytes.Buffer
+	if n, err := laptop.Export(&export); err != nil || n != 2 {
+		t.Fatalf("Export = %d, %v; want 2 rows", n, err)
+	}
+	if _, err := laptop.db.Exec(`UPDATE machines SET name = 'laptop'`); err != nil {
+		t.Fatal(err)
+	}
+	export.Reset()
+	if _, err := laptop.Export(&export); err != nil {
+		t.Fatal(err)
+	}
+	var first ExportRow
+	if err := json.Unmarshal(bytes.SplitN(export.Bytes(), []byte("\n"), 2)[0], &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.UUID == "" || first.MachineID != laptop.machineID || first.Machine != "laptop" {
+		t.Errorf("first row = %+v, want a uuid and the laptop's machine", first)
+	}
+
+	desktop := newTestTracker(t)
+	if err := desktop.TrackRecord(Record{OriginalCmd: "ls", SnipCmd: "ls", InputTokens: 50, OutputTokens: 10, Filter: "ls"}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := desktop.Import(bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Rows: 2, Added: 2}) {
+		t.Errorf("first import = %+v, want 2 added", stats)
+	}
+	stats, err = desktop.Import(bytes.NewReader(export.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Rows: 2, Duplicates: 2}) {
+		t.Errorf("second import = %+v, want 2 duplicates", stats)
+	}
+	// The laptop importing its own rows back, via the desktop, adds nothing.
+	var roundTrip bytes.Buffer
+	if _, err := desktop.Export(&roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = laptop.Import(&roundTrip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (ImportStats{Rows: 3, Added: 1, Own: 2}) {
+		t.Errorf("round trip = %+v, want 1 added and 2 own", stats)
+	}
+
+	groups, err := desktop.GetBreakdown("machine", Scope{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0].Group != "laptop" || groups[0].Count != 2 || groups[0].SavedTokens != 1100 {
+		t.Fatalf("machine breakdown = %+v, want laptop first with 2 commands and 1100 saved", groups)
+	}
+	summary, err := desktop.GetSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TotalCommands != 3 || summary.TotalSaved != 1140 {
+		t.Errorf("summary = %d commands, %d saved; want 3 and 1140", summary.TotalCommands, summary.TotalSaved)
+	}
+
+	if _, err := desktop.Import(strings.NewReader(`{"uuid":"x","machine_id":"y","timestamp":"yesterday"}`)); err == nil {
+		t.Error("Import accepted a row with a bad timestamp")
+	}
+}
@@ -141,22 +141,16 @@ func ApplyTestPipeline(f *filter.Filter, input string) (string, error) {
 		}
 	}
 
+	// Inline tests describe a successful run; templates that read .run see
+	// exit code 0.
 	ar := filter.ActionResult{
 		Lines:    lines,
-		Metadata: make(map[string]any),
+		Metadata: map[string]any{filter.RunKey: filter.RunContext{}},
 	}
 
-	for i, action := range f.Pipeline {
-		fn, ok := filter.GetAction(action.ActionName)
-		if !ok {
-			return "", fmt.Errorf("unknown action %q at pipeline[%d]", action.ActionName, i)
-		}
-
-		var err error
-		ar, err = fn(ar, action.Params)
-		if err != nil {
-			return "", fmt.Errorf("pipeline[%d] %s: %w", i, action.ActionName, err)
-		}
+	ar, err := filter.RunPipeline(f.Pipeline, ar)
+	if err != nil {
+		return "", err
 	}
 
 	return strings.Join(ar.Lines, "\n") + "\n", nil
@@ -3,6 +3,7 @@ package snip
 import (
 	"github.com/edouard-claude/snip/internal/cli"
 	"github.com/edouard-claude/snip/internal/filter"
+	"github.com/edouard-claude/snip/internal/tokens"
 )
 
 // Run executes snip the same way cmd/snip does.
@@ -10,5 +11,7 @@ import (
 func Run(args []string) int {
 	fs := EmbeddedFilters
 	filter.EmbeddedFS = &fs
+	vocab := EmbeddedTokenizer
+	tokens.EmbeddedFS = &vocab
 	return cli.Run(args)
 }
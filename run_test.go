@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/edouard-claude/snip/internal/filter"
+	"github.com/edouard-claude/snip/internal/tokens"
 )
 
 // TestRun_VersionFlag is a smoke test verifying that Run wires the embedded
@@ -11,6 +12,7 @@ import (
 // for the --version short-circuit path.
 func TestRun_VersionFlag(t *testing.T) {
 	filter.EmbeddedFS = nil // ensure Run sets it
+	tokens.EmbeddedFS = nil
 
 	if code := Run([]string{"snip", "--version"}); code != 0 {
 		t.Fatalf("Run(--version) = %d, want 0", code)
@@ -19,6 +21,32 @@ func TestRun_VersionFlag(t *testing.T) {
 	if filter.EmbeddedFS == nil {
 		t.Fatal("Run did not wire filter.EmbeddedFS")
 	}
+	if tokens.EmbeddedFS == nil {
+		t.Fatal("Run did not wire tokens.EmbeddedFS")
+	}
+}
+
+// TestEmbeddedTokenizerCounts verifies the vocabulary shipped in the binary is
+// cl100k_base: the counts are the ones OpenAI's tiktoken reports.
+func TestEmbeddedTokenizerCounts(t *testing.T) {
+	tokens.EmbeddedFS = &EmbeddedTokenizer
+	est, err := tokens.New("", "")
+	if err != nil {
+		t.Fatalf("load embedded vocabulary: %v", err)
+	}
+	if est.Name() != "bpe:cl100k_base" {
+		t.Errorf("name = %q", est.Name())
+	}
+	for s, want := range map[string]int{
+		"hello world":        2,
+		"tiktoken is great!": 6,
+		"func main() {\n\tfmt.Println(\"hello, world\")\n}\n": 12,
+		" M internal/cli/cli.go\n?? filters/helm-get.yaml\n":  13,
+	} {
+		if n := est.Count(s); n != want {
+			t.Errorf("Count(%q) = %d, want %d", s, n, want)
+		}
+	}
 }
 
 // TestRun_NoArgs verifies the usage path returns 0, matching the cli.Run